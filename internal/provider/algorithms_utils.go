@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"golang.org/x/crypto/ssh"
+)
+
+// Algorithms implemented by golang.org/x/crypto/ssh, including the legacy ones that are
+// not enabled by default but may be required by older servers
+var (
+	supportedCiphers = []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+		"aes128-cbc", "3des-cbc",
+		"arcfour256", "arcfour128", "arcfour",
+	}
+	supportedKeyExchanges = []string{
+		"curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512",
+		"diffie-hellman-group-exchange-sha256",
+		"diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1",
+		"diffie-hellman-group-exchange-sha1",
+	}
+	supportedMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256", "hmac-sha2-512",
+		"hmac-sha1", "hmac-sha1-96",
+	}
+	supportedHostKeyAlgorithms = []string{
+		ssh.KeyAlgoED25519,
+		ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+		ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA,
+		ssh.KeyAlgoDSA,
+		ssh.CertAlgoED25519v01,
+		ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+		ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01,
+		ssh.CertAlgoDSAv01,
+	}
+)
+
+// algorithmValidators requires a non-empty list of algorithms, all of them supported
+func algorithmValidators(supported []string) []validator.List {
+	return []validator.List{
+		listvalidator.SizeAtLeast(1),
+		listvalidator.ValueStringsAre(stringvalidator.OneOf(supported...)),
+	}
+}
+
+// Validators of the algorithm lists
+var (
+	cipherValidators           = algorithmValidators(supportedCiphers)
+	keyExchangeValidators      = algorithmValidators(supportedKeyExchanges)
+	macValidators              = algorithmValidators(supportedMACs)
+	hostKeyAlgorithmValidators = algorithmValidators(supportedHostKeyAlgorithms)
+)
+
+// applyAlgorithms restricts the algorithms negotiated by the client configuration to
+// those configured for the connection. Configured host key algorithms are narrowed down
+// to the ones able to verify the host keys already known for the target, if any.
+func applyAlgorithms(sshConfig *ssh.ClientConfig, config SSHConnectionConfig, target string) error {
+	sshConfig.Ciphers = config.Ciphers
+	sshConfig.KeyExchanges = config.KeyExchanges
+	sshConfig.MACs = config.MACs
+
+	if len(config.HostKeyAlgorithms) == 0 {
+		return nil
+	}
+	if len(sshConfig.HostKeyAlgorithms) == 0 {
+		sshConfig.HostKeyAlgorithms = config.HostKeyAlgorithms
+		return nil
+	}
+
+	var algorithms []string
+	for _, algorithm := range config.HostKeyAlgorithms {
+		for _, known := range sshConfig.HostKeyAlgorithms {
+			if algorithm == known {
+				algorithms = append(algorithms, algorithm)
+				break
+			}
+		}
+	}
+	if len(algorithms) == 0 {
+		return fmt.Errorf("none of the host_key_algorithms (%s) can verify the known host keys of %s, which require one of %s",
+			strings.Join(config.HostKeyAlgorithms, ", "), target, strings.Join(sshConfig.HostKeyAlgorithms, ", "))
+	}
+	sshConfig.HostKeyAlgorithms = algorithms
+	return nil
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestAlgorithmValidators(t *testing.T) {
+	list := func(values ...string) types.List {
+		elements := make([]attr.Value, len(values))
+		for i, value := range values {
+			elements[i] = types.StringValue(value)
+		}
+		return types.ListValueMust(types.StringType, elements)
+	}
+
+	tests := []struct {
+		name       string
+		validators []validator.List
+		value      types.List
+		wantErr    bool
+	}{
+		{name: "supported ciphers", validators: cipherValidators, value: list("chacha20-poly1305@openssh.com", "aes256-ctr")},
+		{name: "legacy cipher", validators: cipherValidators, value: list("aes128-cbc")},
+		{name: "unsupported cipher", validators: cipherValidators, value: list("aes256-ctr", "blowfish-cbc"), wantErr: true},
+		{name: "empty list", validators: cipherValidators, value: list(), wantErr: true},
+		{name: "null list", validators: cipherValidators, value: types.ListNull(types.StringType)},
+		{name: "supported key exchange", validators: keyExchangeValidators, value: list("curve25519-sha256")},
+		{name: "cipher as key exchange", validators: keyExchangeValidators, value: list("aes256-ctr"), wantErr: true},
+		{name: "supported mac", validators: macValidators, value: list("hmac-sha2-256-etm@openssh.com")},
+		{name: "unsupported mac", validators: macValidators, value: list("umac-64@openssh.com"), wantErr: true},
+		{name: "supported host key algorithm", validators: hostKeyAlgorithmValidators, value: list("ssh-ed25519", "rsa-sha2-512-cert-v01@openssh.com")},
+		{name: "unsupported host key algorithm", validators: hostKeyAlgorithmValidators, value: list("sk-ssh-ed25519@openssh.com"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validator.ListRequest{Path: path.Root("algorithms"), ConfigValue: tt.value}
+			resp := &validator.ListResponse{}
+			for _, v := range tt.validators {
+				v.ValidateList(context.Background(), req, resp)
+			}
+			if resp.Diagnostics.HasError() != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, resp.Diagnostics)
+			}
+		})
+	}
+}
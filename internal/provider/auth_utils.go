@@ -0,0 +1,352 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// newAuthMethods builds the authentication methods for a connection. All public key
+// signers are offered through a single method, since the SSH client only attempts each
+// method once. The returned cleanup function releases resources such as the agent
+// connection and must be called once the handshake has completed.
+func newAuthMethods(config SSHConnectionConfig) ([]ssh.AuthMethod, func(), error) {
+	var methods []ssh.AuthMethod
+	var signers []ssh.Signer
+	cleanup := func() {}
+
+	var cert *ssh.Certificate
+	if config.Certificate != nil {
+		parsed, err := parseCertificate(*config.Certificate)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		cert = parsed
+	}
+
+	if config.PrivateKey != nil {
+		signer, err := parsePrivateKey(*config.PrivateKey, config.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		if cert != nil {
+			certSigner, err := ssh.NewCertSigner(cert, signer)
+			if err != nil {
+				return nil, cleanup, fmt.Errorf("unable to use certificate with private key: %w", err)
+			}
+			signers = append(signers, certSigner)
+		}
+		signers = append(signers, signer)
+	}
+
+	var agentClient agent.ExtendedAgent
+	if config.Agent != nil && *config.Agent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if config.AgentSocket != nil {
+			socket = expandPath(*config.AgentSocket)
+		}
+		if socket == "" {
+			return nil, cleanup, fmt.Errorf("ssh agent authentication requested, but no agent_socket is configured and SSH_AUTH_SOCK is not set")
+		}
+
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("unable to connect to ssh agent at %s: %w", socket, err)
+		}
+		cleanup = func() { conn.Close() }
+		agentClient = agent.NewClient(conn)
+	}
+
+	if len(signers) > 0 || agentClient != nil {
+		methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			if agentClient == nil {
+				return signers, nil
+			}
+			agentSigners, err := agentSigners(agentClient, config.AgentIdentity)
+			if err != nil {
+				return nil, err
+			}
+
+			// Offer the certificate for the agent identity holding its key, unless the
+			// private key was configured directly
+			if cert != nil && config.PrivateKey == nil {
+				for _, signer := range agentSigners {
+					if bytes.Equal(signer.PublicKey().Marshal(), cert.Key.Marshal()) {
+						certSigner, err := ssh.NewCertSigner(cert, signer)
+						if err != nil {
+							return nil, fmt.Errorf("unable to use certificate with ssh agent identity: %w", err)
+						}
+						agentSigners = append([]ssh.Signer{certSigner}, agentSigners...)
+						break
+					}
+				}
+			}
+			return append(append([]ssh.Signer{}, signers...), agentSigners...), nil
+		}))
+	}
+
+	if cert != nil && config.PrivateKey == nil && agentClient == nil {
+		return nil, cleanup, fmt.Errorf("a certificate requires either private_key or agent authentication")
+	}
+
+	if config.Password != nil {
+		methods = append(methods, ssh.Password(*config.Password))
+	}
+
+	// Keyboard-interactive comes last so that it can complete a partial success of the
+	// methods above when the server requires several of them
+	if config.Password != nil || len(config.KeyboardInteractiveAnswers) > 0 || config.TOTPSecret != nil {
+		methods = append(methods, ssh.KeyboardInteractive(keyboardInteractiveChallenge(config)))
+	}
+
+	return methods, cleanup, nil
+}
+
+// readCredentialFiles returns a copy of the config with the private key and the password
+// read from private_key_path and password_file, so that only their paths are stored in state
+func readCredentialFiles(config SSHConnectionConfig) (SSHConnectionConfig, error) {
+	if config.PrivateKey != nil && config.PrivateKeyPath != nil {
+		return config, fmt.Errorf("private_key and private_key_path cannot both be set for %s", hostDescription(config))
+	}
+	if config.Password != nil && config.PasswordFile != nil {
+		return config, fmt.Errorf("password and password_file cannot both be set for %s", hostDescription(config))
+	}
+
+	if config.PrivateKeyPath != nil {
+		content, err := os.ReadFile(expandPath(*config.PrivateKeyPath))
+		if err != nil {
+			return config, fmt.Errorf("unable to read private_key_path: %w", err)
+		}
+		privateKey := string(content)
+		config.PrivateKey = &privateKey
+	}
+	if config.PasswordFile != nil {
+		content, err := os.ReadFile(expandPath(*config.PasswordFile))
+		if err != nil {
+			return config, fmt.Errorf("unable to read password_file: %w", err)
+		}
+		password := strings.TrimRight(string(content), "\r\n")
+		config.Password = &password
+	}
+	return config, nil
+}
+
+// parsePrivateKey parses a PEM encoded private key, decrypting it with the passphrase
+// when given. Errors distinguish between encrypted keys, wrong passphrases and
+// unsupported formats.
+func parsePrivateKey(key string, passphrase *string) (ssh.Signer, error) {
+	var signer ssh.Signer
+	var err error
+	if passphrase != nil {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(*passphrase))
+		// A passphrase configured for an unencrypted key is ignored
+		if err != nil && (err.Error() == "ssh: not an encrypted key" || err.Error() == "ssh: key is not password protected") {
+			signer, err = ssh.ParsePrivateKey([]byte(key))
+		}
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(key))
+	}
+	if err == nil {
+		return signer, nil
+	}
+
+	var missingErr *ssh.PassphraseMissingError
+	switch {
+	case errors.As(err, &missingErr):
+		return nil, fmt.Errorf("unable to parse private key: the key is encrypted, but no private_key_passphrase was provided")
+	case errors.Is(err, x509.IncorrectPasswordError):
+		return nil, fmt.Errorf("unable to parse private key: the private_key_passphrase is incorrect")
+	case err.Error() == "ssh: no key found":
+		return nil, fmt.Errorf("unable to parse private key: no PEM encoded private key found, expected an OpenSSH, PKCS#1, PKCS#8 or SEC 1 private key")
+	case strings.HasPrefix(err.Error(), "ssh: unsupported key type"), strings.Contains(err.Error(), "cannot decode encrypted private keys"):
+		return nil, fmt.Errorf("unable to parse private key: the key format is not supported: %w", err)
+	default:
+		return nil, fmt.Errorf("unable to parse private key: %w", err)
+	}
+}
+
+// otpPromptRegex matches keyboard-interactive prompts asking for a one-time password
+var otpPromptRegex = regexp.MustCompile(`(?i)(verification|one-time|otp|token|passcode|authenticator|2fa|mfa|\bcode\b)`)
+
+// keyboardInteractiveChallenge answers keyboard-interactive prompts from the configured
+// static answers, the TOTP secret or the password, in that order of preference
+func keyboardInteractiveChallenge(config SSHConnectionConfig) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			answer, err := answerPrompt(question, config)
+			if err != nil {
+				return nil, err
+			}
+			answers[i] = answer
+		}
+		return answers, nil
+	}
+}
+
+// answerPrompt returns the answer to a single keyboard-interactive prompt
+func answerPrompt(prompt string, config SSHConnectionConfig) (string, error) {
+	lowerPrompt := strings.ToLower(prompt)
+
+	// Prefer the longest matching static answer, so that specific keys win over generic ones
+	var match string
+	var found bool
+	for key := range config.KeyboardInteractiveAnswers {
+		if strings.Contains(lowerPrompt, strings.ToLower(key)) && (!found || len(key) > len(match)) {
+			match = key
+			found = true
+		}
+	}
+	if found {
+		return config.KeyboardInteractiveAnswers[match], nil
+	}
+
+	if config.TOTPSecret != nil && otpPromptRegex.MatchString(prompt) {
+		return generateTOTP(*config.TOTPSecret, time.Now())
+	}
+	if config.Password != nil && strings.Contains(lowerPrompt, "password") {
+		return *config.Password, nil
+	}
+
+	return "", fmt.Errorf("no answer configured for keyboard-interactive prompt %q", strings.TrimSpace(prompt))
+}
+
+// generateTOTP computes the RFC 6238 time-based one-time password for the given base32
+// secret, using 30 second steps, HMAC-SHA1 and 6 digits
+func generateTOTP(secret string, now time.Time) (string, error) {
+	normalized := strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(secret))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(normalized)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode totp_secret as base32: %w", err)
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(now.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation as described in RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1000000), nil
+}
+
+// agentSigners returns the signers held by the agent, optionally restricted to the
+// identity matching the given comment, SHA256 fingerprint or public key
+func agentSigners(agentClient agent.ExtendedAgent, identity *string) ([]ssh.Signer, error) {
+	signers, err := agentClient.Signers()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list ssh agent identities: %w", err)
+	}
+	if identity == nil {
+		return signers, nil
+	}
+
+	keys, err := agentClient.List()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list ssh agent identities: %w", err)
+	}
+
+	want := strings.TrimSpace(*identity)
+	var wantKey []byte
+	if key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(want)); err == nil {
+		wantKey = key.Marshal()
+	}
+
+	var matched []ssh.Signer
+	for _, key := range keys {
+		if key.Comment != want && ssh.FingerprintSHA256(key) != want && !bytes.Equal(key.Marshal(), wantKey) {
+			continue
+		}
+		for _, signer := range signers {
+			if bytes.Equal(signer.PublicKey().Marshal(), key.Marshal()) {
+				matched = append(matched, signer)
+			}
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no ssh agent identity matches %q", want)
+	}
+	return matched, nil
+}
+
+// parseCertificate parses an OpenSSH user certificate in authorized_keys format
+func parseCertificate(certificate string) (*ssh.Certificate, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certificate))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %w", err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("unable to parse certificate: got a plain %s public key", key.Type())
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("unable to parse certificate: %q is a host certificate, not a user certificate", cert.KeyId)
+	}
+	return cert, nil
+}
+
+// describeCertificate summarizes the validity of the configured certificate, to help
+// explain why the server rejected it
+func describeCertificate(config SSHConnectionConfig) string {
+	if config.Certificate == nil {
+		return ""
+	}
+	cert, err := parseCertificate(*config.Certificate)
+	if err != nil {
+		return ""
+	}
+
+	var problems []string
+	now := time.Now()
+	validAfter := time.Unix(int64(cert.ValidAfter), 0).UTC()
+	validBefore := "forever"
+	if cert.ValidBefore != ssh.CertTimeInfinity {
+		before := time.Unix(int64(cert.ValidBefore), 0).UTC()
+		validBefore = before.Format(time.RFC3339)
+		if now.After(before) {
+			problems = append(problems, "the certificate has expired")
+		}
+	}
+	if now.Before(validAfter) {
+		problems = append(problems, "the certificate is not yet valid")
+	}
+	if config.User != nil && len(cert.ValidPrincipals) > 0 && !slices.Contains(cert.ValidPrincipals, *config.User) {
+		problems = append(problems, fmt.Sprintf("user %q is not a valid principal", *config.User))
+	}
+
+	description := fmt.Sprintf("certificate %q (serial %d) is valid for principals [%s] from %s until %s",
+		cert.KeyId, cert.Serial, strings.Join(cert.ValidPrincipals, ", "), validAfter.Format(time.RFC3339), validBefore)
+	if len(problems) > 0 {
+		description += ": " + strings.Join(problems, ", ")
+	}
+	return description
+}
+
+// annotateAuthError adds details about the configured credentials to authentication
+// failures returned by the SSH handshake
+func annotateAuthError(err error, config SSHConnectionConfig) error {
+	if !strings.Contains(err.Error(), "unable to authenticate") {
+		return err
+	}
+	if description := describeCertificate(config); description != "" {
+		return fmt.Errorf("%w (%s)", err, description)
+	}
+	return err
+}
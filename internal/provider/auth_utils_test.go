@@ -0,0 +1,205 @@
+package provider
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestReadCredentialFiles(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "id_ed25519")
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(keyFile, []byte("key from file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(passwordFile, []byte("secret\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name         string
+		config       SSHConnectionConfig
+		wantKey      *string
+		wantPassword *string
+		wantErr      string
+	}{
+		{name: "nothing to read", config: SSHConnectionConfig{PrivateKey: str("key"), Password: str("pw")}, wantKey: str("key"), wantPassword: str("pw")},
+		{name: "files", config: SSHConnectionConfig{PrivateKeyPath: &keyFile, PasswordFile: &passwordFile}, wantKey: str("key from file"), wantPassword: str("secret")},
+		{name: "private key conflicts", config: SSHConnectionConfig{PrivateKey: str("key"), PrivateKeyPath: &keyFile}, wantErr: "private_key and private_key_path cannot both be set"},
+		{name: "password conflicts", config: SSHConnectionConfig{Password: str("pw"), PasswordFile: &passwordFile}, wantErr: "password and password_file cannot both be set"},
+		{name: "missing key file", config: SSHConnectionConfig{PrivateKeyPath: &missing}, wantErr: "unable to read private_key_path"},
+		{name: "missing password file", config: SSHConnectionConfig{PasswordFile: &missing}, wantErr: "unable to read password_file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := readCredentialFiles(tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *config.PrivateKey != *tt.wantKey || *config.Password != *tt.wantPassword {
+				t.Errorf("got key %q and password %q", *config.PrivateKey, *config.Password)
+			}
+		})
+	}
+}
+
+func TestWithOverrides_Credentials(t *testing.T) {
+	str := func(s string) *string { return &s }
+	connection := SSHConnectionConfig{PrivateKeyPath: str("~/.ssh/id_ed25519"), Password: str("pw")}
+
+	config := connection.withOverrides(SSHConnectionConfig{PrivateKey: str("key"), PasswordFile: str("/run/secrets/pw")})
+	if config.PrivateKeyPath != nil || config.Password != nil {
+		t.Errorf("overridden credentials were kept: private_key_path %v, password %v", config.PrivateKeyPath, config.Password)
+	}
+	if *config.PrivateKey != "key" || *config.PasswordFile != "/run/secrets/pw" {
+		t.Errorf("overrides were not applied: %+v", config)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := ssh.MarshalPrivateKeyWithPassphrase(private, "", []byte("right"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainKey, encryptedKey := string(pem.EncodeToMemory(plain)), string(pem.EncodeToMemory(encrypted))
+	unsupportedKey := string(pem.EncodeToMemory(&pem.Block{Type: "UNKNOWN PRIVATE KEY", Bytes: []byte("key")}))
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name       string
+		key        string
+		passphrase *string
+		wantErr    string
+	}{
+		{name: "unencrypted", key: plainKey},
+		{name: "passphrase of unencrypted key is ignored", key: plainKey, passphrase: str("unused")},
+		{name: "encrypted", key: encryptedKey, passphrase: str("right")},
+		{name: "missing passphrase", key: encryptedKey, wantErr: "no private_key_passphrase was provided"},
+		{name: "incorrect passphrase", key: encryptedKey, passphrase: str("wrong"), wantErr: "the private_key_passphrase is incorrect"},
+		{name: "not a key", key: "ssh-ed25519 AAAA", wantErr: "no PEM encoded private key found"},
+		{name: "unsupported format", key: unsupportedKey, wantErr: "the key format is not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := parsePrivateKey(tt.key, tt.passphrase)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signer.PublicKey().Type() != ssh.KeyAlgoED25519 {
+				t.Errorf("unexpected key type %s", signer.PublicKey().Type())
+			}
+		})
+	}
+}
+
+func TestGenerateTOTP(t *testing.T) {
+	// Test vectors of RFC 6238 for SHA-1, of which the last 6 of the 8 digits are used.
+	// The secret is the ASCII string "12345678901234567890".
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		name   string
+		secret string
+		time   int64
+		want   string
+	}{
+		{name: "59", secret: secret, time: 59, want: "287082"},
+		{name: "1111111109", secret: secret, time: 1111111109, want: "081804"},
+		{name: "1111111111", secret: secret, time: 1111111111, want: "050471"},
+		{name: "1234567890", secret: secret, time: 1234567890, want: "005924"},
+		{name: "2000000000", secret: secret, time: 2000000000, want: "279037"},
+		{name: "20000000000", secret: secret, time: 20000000000, want: "353130"},
+		{name: "lowercase with spaces and padding", secret: "gezd gnbv gy3t qojq gezd gnbv gy3t qojq====", time: 59, want: "287082"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := generateTOTP(tt.secret, time.Unix(tt.time, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, code)
+			}
+		})
+	}
+
+	if _, err := generateTOTP("not base32!", time.Unix(59, 0)); err == nil || !strings.Contains(err.Error(), "unable to decode totp_secret") {
+		t.Errorf("invalid secret was not rejected: %v", err)
+	}
+}
+
+func TestAnswerPrompt(t *testing.T) {
+	str := func(s string) *string { return &s }
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	code, err := generateTOTP(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		prompt  string
+		config  SSHConnectionConfig
+		want    string
+		wantErr string
+	}{
+		{name: "password", prompt: "Password: ", config: SSHConnectionConfig{Password: str("pw")}, want: "pw"},
+		{name: "static answer", prompt: "Favourite colour? ", config: SSHConnectionConfig{KeyboardInteractiveAnswers: map[string]string{"colour": "blue"}}, want: "blue"},
+		{name: "longest static answer wins", prompt: "Backup code: ", config: SSHConnectionConfig{KeyboardInteractiveAnswers: map[string]string{"code": "1", "backup code": "2"}}, want: "2"},
+		{name: "static answer wins over password", prompt: "LDAP password: ", config: SSHConnectionConfig{Password: str("pw"), KeyboardInteractiveAnswers: map[string]string{"ldap": "ldap pw"}}, want: "ldap pw"},
+		{name: "totp", prompt: "Verification code: ", config: SSHConnectionConfig{Password: str("pw"), TOTPSecret: &secret}, want: code},
+		{name: "one-time password is not the password", prompt: "One-time password: ", config: SSHConnectionConfig{Password: str("pw"), TOTPSecret: &secret}, want: code},
+		{name: "no answer", prompt: "  Username:  ", config: SSHConnectionConfig{Password: str("pw")}, wantErr: `no answer configured for keyboard-interactive prompt "Username:"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := answerPrompt(tt.prompt, tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if answer != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, answer)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// Defaults of privilege escalation
+const (
+	defaultBecomeUser   = "root"
+	defaultBecomeMethod = becomeMethodSudo
+)
+
+// becomeStdinLineLength is the length of the base64 lines carrying the standard input
+// through a terminal, well below the line limit of terminals in canonical mode
+const becomeStdinLineLength = 76
+
+// BecomeConfig holds the privilege escalation settings of the provider, a named connection
+// or a resource
+type BecomeConfig struct {
+	Become   *bool
+	User     *string
+	Method   *string
+	Password *string
+}
+
+func (m *SSHBecomeModel) toConfig() BecomeConfig {
+	var config BecomeConfig
+	if !m.Become.IsNull() {
+		value := m.Become.ValueBool()
+		config.Become = &value
+	}
+	if !m.BecomeUser.IsNull() {
+		value := m.BecomeUser.ValueString()
+		config.User = &value
+	}
+	if !m.BecomeMethod.IsNull() {
+		value := m.BecomeMethod.ValueString()
+		config.Method = &value
+	}
+	if !m.BecomePassword.IsNull() {
+		value := m.BecomePassword.ValueString()
+		config.Password = &value
+	}
+	return config
+}
+
+// withDefaults fills the settings that are not set from the defaults
+func (c BecomeConfig) withDefaults(defaults BecomeConfig) BecomeConfig {
+	if c.Become == nil {
+		c.Become = defaults.Become
+	}
+	if c.User == nil {
+		c.User = defaults.User
+	}
+	if c.Method == nil {
+		c.Method = defaults.Method
+	}
+	if c.Password == nil {
+		c.Password = defaults.Password
+	}
+	return c
+}
+
+// becomeOptions controls how the privileges of commands and file operations are escalated
+type becomeOptions struct {
+	Method string
+	User   string
+	// Password answers the prompt of the method, which must not prompt when it is empty
+	Password string
+}
+
+// resolveBecome returns the privilege escalation of a resource, whose settings override
+// those of its named connection and of the provider, or nil when privileges are not
+// escalated
+func (m *SSHManager) resolveBecome(connection types.String, model *SSHBecomeModel) *becomeOptions {
+	config := model.toConfig()
+	if !connection.IsNull() {
+		config = config.withDefaults(m.connections[connection.ValueString()].Become)
+	}
+	config = config.withDefaults(m.become)
+	if config.Become == nil || !*config.Become {
+		return nil
+	}
+
+	options := &becomeOptions{User: defaultBecomeUser, Method: defaultBecomeMethod}
+	if config.User != nil {
+		options.User = *config.User
+	}
+	if config.Method != nil {
+		options.Method = *config.Method
+	}
+	if config.Password != nil {
+		options.Password = *config.Password
+	}
+	return options
+}
+
+// becomeSession escalates the privileges of a command. It answers the password prompt of
+// the escalation method, hides everything written before the command starts, and holds
+// back the standard input until then. Since su and doas only read passwords from a
+// terminal, a pseudo-terminal is requested for them when a password is set, which merges
+// stderr into stdout.
+type becomeSession struct {
+	options *becomeOptions
+	// marker is written to each stream once privileges are escalated
+	marker string
+	// prompt is the password prompt of sudo, other methods prompt in the terminal
+	prompt   string
+	terminal bool
+
+	stdin          io.WriteCloser
+	stdout, stderr *becomeStream
+	rejected       bool
+
+	escalated     chan struct{}
+	escalatedOnce sync.Once
+	finished      chan struct{}
+}
+
+// startBecome prepares the session to escalate the privileges of the command, which must
+// not have started yet, and returns the command to start instead
+func startBecome(session *ssh.Session, command string, options execOptions) (*becomeSession, string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", fmt.Errorf("failed to generate privilege escalation marker: %w", err)
+	}
+
+	b := &becomeSession{
+		options:   options.Become,
+		marker:    "BECOME-SUCCESS-" + hex.EncodeToString(id),
+		terminal:  options.Become.Password != "" && options.Become.Method != becomeMethodSudo,
+		escalated: make(chan struct{}),
+		finished:  make(chan struct{}),
+	}
+	if options.Become.Password != "" && !b.terminal {
+		b.prompt = fmt.Sprintf("[become %s] password:", hex.EncodeToString(id[:4]))
+	}
+
+	// The command only starts once the marker has been written
+	script := "echo " + b.marker + "\n"
+	switch {
+	case !b.terminal:
+		script += "echo " + b.marker + " >&2\n" + command
+	case options.Stdin != nil:
+		// Terminals mangle binary data, so the standard input is sent base64-encoded
+		script += "base64 -d | sh -c " + shellQuote(command)
+	default:
+		script += "exec </dev/null\n" + command
+	}
+
+	var wrapped string
+	switch b.options.Method {
+	case becomeMethodSudo:
+		if b.prompt != "" {
+			wrapped = fmt.Sprintf("sudo -S -p %s -u %s -- sh -c %s", shellQuote(b.prompt), shellQuote(b.options.User), shellQuote(script))
+		} else {
+			wrapped = fmt.Sprintf("sudo -n -u %s -- sh -c %s", shellQuote(b.options.User), shellQuote(script))
+		}
+	case becomeMethodSu:
+		wrapped = fmt.Sprintf("su -s /bin/sh %s -c %s", shellQuote(b.options.User), shellQuote(script))
+	case becomeMethodDoas:
+		if b.terminal {
+			wrapped = fmt.Sprintf("doas -u %s sh -c %s", shellQuote(b.options.User), shellQuote(script))
+		} else {
+			wrapped = fmt.Sprintf("doas -n -u %s sh -c %s", shellQuote(b.options.User), shellQuote(script))
+		}
+	default:
+		return nil, "", fmt.Errorf("unsupported become_method %q, expected 'sudo', 'su' or 'doas'", b.options.Method)
+	}
+
+	if b.terminal {
+		// Neither echo the input nor translate the line endings of the output
+		modes := ssh.TerminalModes{ssh.ECHO: 0, ssh.ONLCR: 0}
+		if err := session.RequestPty("dumb", 24, 80, modes); err != nil {
+			return nil, "", fmt.Errorf("failed to request a terminal for %s: %w", b.options.Method, err)
+		}
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open stdin: %w", err)
+	}
+	b.stdin = stdin
+
+	b.stdout = &becomeStream{session: b, out: session.Stdout, detectPrompt: b.terminal, signalEscalation: true}
+	session.Stdout = b.stdout
+	if !b.terminal {
+		b.stderr = &becomeStream{session: b, out: session.Stderr, detectPrompt: b.prompt != ""}
+		session.Stderr = b.stderr
+	}
+
+	return b, wrapped, nil
+}
+
+// writeStdin writes the standard input once privileges are escalated, and closes it. It
+// returns early when the command finishes first.
+func (b *becomeSession) writeStdin(stdin []byte) {
+	select {
+	case <-b.escalated:
+	case <-b.finished:
+		return
+	}
+
+	if !b.terminal {
+		if stdin != nil {
+			b.stdin.Write(stdin)
+		}
+		b.stdin.Close()
+		return
+	}
+	if stdin == nil {
+		return
+	}
+
+	// End of file is sent as Ctrl-D at the start of a line
+	encoded := base64.StdEncoding.EncodeToString(stdin)
+	for len(encoded) > 0 {
+		n := min(len(encoded), becomeStdinLineLength)
+		if _, err := io.WriteString(b.stdin, encoded[:n]+"\n"); err != nil {
+			return
+		}
+		encoded = encoded[n:]
+	}
+	io.WriteString(b.stdin, "\x04")
+}
+
+// answer writes the password for the first prompt. Prompting again means it was rejected,
+// so the standard input is closed for the escalation to fail.
+func (b *becomeSession) answer(prompts int) {
+	if b.options.Password == "" || b.rejected {
+		return
+	}
+	if prompts > 1 {
+		b.rejected = true
+		b.stdin.Close()
+		return
+	}
+	io.WriteString(b.stdin, b.options.Password+"\n")
+}
+
+func (b *becomeSession) escalate() {
+	b.escalatedOnce.Do(func() { close(b.escalated) })
+}
+
+// finish stops waiting for the escalation, once the session is done
+func (b *becomeSession) finish() {
+	close(b.finished)
+}
+
+// err reports an escalation that failed, so that the command never ran, along with what
+// the escalation method wrote
+func (b *becomeSession) err() error {
+	if b.stdout.passed {
+		return nil
+	}
+
+	output := string(b.stdout.pending)
+	if b.stderr != nil {
+		output += string(b.stderr.pending)
+	}
+	if b.prompt != "" {
+		output = strings.ReplaceAll(output, b.prompt, "")
+	}
+
+	message := fmt.Sprintf("failed to become %s with %s", b.options.User, b.options.Method)
+	if b.rejected {
+		message += ", the become_password was rejected"
+	}
+	if output = strings.TrimSpace(output); output != "" {
+		message += "\n" + tailLines("Output", output)
+	}
+	return errors.New(message)
+}
+
+// becomeStream holds back what is written to a stream of the session until the marker,
+// looking for password prompts, and passes the rest on
+type becomeStream struct {
+	session *becomeSession
+	out     io.Writer
+	// detectPrompt looks for password prompts in the stream
+	detectPrompt bool
+	// signalEscalation reports the escalation once the marker is written to the stream
+	signalEscalation bool
+
+	pending  []byte
+	answered int
+	prompts  int
+	passed   bool
+}
+
+func (s *becomeStream) Write(p []byte) (int, error) {
+	if s.passed {
+		return s.out.Write(p)
+	}
+
+	s.pending = append(s.pending, p...)
+	marker := []byte(s.session.marker + "\n")
+	if i := bytes.Index(s.pending, marker); i >= 0 {
+		rest := s.pending[i+len(marker):]
+		s.pending = s.pending[:i]
+		s.passed = true
+		if s.signalEscalation {
+			s.session.escalate()
+		}
+		if len(rest) > 0 {
+			if _, err := s.out.Write(rest); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+
+	if s.detectPrompt && s.isPrompt(s.pending[s.answered:]) {
+		s.answered = len(s.pending)
+		s.prompts++
+		s.session.answer(s.prompts)
+	}
+	return len(p), nil
+}
+
+// isPrompt reports whether the output ends with a password prompt. The prompt of sudo is
+// known, other prompts end with a colon.
+func (s *becomeStream) isPrompt(output []byte) bool {
+	if s.session.prompt != "" {
+		return bytes.Contains(output, []byte(s.session.prompt))
+	}
+	return bytes.HasSuffix(bytes.TrimRight(output, " "), []byte(":"))
+}
@@ -1,53 +1,309 @@
 package provider
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 var SSHConnectionSchema = struct {
-	Host                 schema.StringAttribute
-	User                 schema.StringAttribute
-	Password             schema.StringAttribute
-	PrivateKey           schema.StringAttribute
-	Port                 schema.Int64Attribute
-	UseProviderAsBastion schema.BoolAttribute
-	Bastion              schema.SingleNestedAttribute
+	Host                       schema.StringAttribute
+	User                       schema.StringAttribute
+	Password                   schema.StringAttribute
+	PrivateKey                 schema.StringAttribute
+	PrivateKeyPassphrase       schema.StringAttribute
+	PrivateKeyPath             schema.StringAttribute
+	PasswordFile               schema.StringAttribute
+	KeyboardInteractiveAnswers schema.MapAttribute
+	TOTPSecret                 schema.StringAttribute
+	Certificate                schema.StringAttribute
+	Port                       schema.Int64Attribute
+	ProxyCommand               schema.StringAttribute
+	Proxy                      schema.SingleNestedAttribute
+	Agent                      schema.BoolAttribute
+	AgentSocket                schema.StringAttribute
+	AgentIdentity              schema.StringAttribute
+	KnownHostsFiles            schema.ListAttribute
+	HostKeys                   schema.ListAttribute
+	StrictHostKeyChecking      schema.StringAttribute
+	ConnectTimeout             schema.StringAttribute
+	ConnectRetries             schema.Int64Attribute
+	ConnectRetryTimeout        schema.StringAttribute
+	ConnectRetryInterval       schema.StringAttribute
+	ConnectRetryMaxInterval    schema.StringAttribute
+	KeepaliveInterval          schema.StringAttribute
+	KeepaliveCountMax          schema.Int64Attribute
+	Ciphers                    schema.ListAttribute
+	KeyExchanges               schema.ListAttribute
+	MACs                       schema.ListAttribute
+	HostKeyAlgorithms          schema.ListAttribute
+	UseProviderAsBastion       schema.BoolAttribute
+	ConnectionName             schema.StringAttribute
+	Bastion                    schema.SingleNestedAttribute
+	JumpHosts                  schema.ListNestedAttribute
 }{
-	Host:                 schema.StringAttribute{Description: "Override the provider's host configuration", Optional: true},
-	User:                 schema.StringAttribute{Description: "Override the provider's user configuration", Optional: true},
-	Password:             schema.StringAttribute{Description: "Override the provider's password configuration", Optional: true, Sensitive: true},
-	PrivateKey:           schema.StringAttribute{Description: "Override the provider's private key configuration", Optional: true, Sensitive: true},
-	Port:                 schema.Int64Attribute{Description: "The port number to connect to", Optional: true},
-	UseProviderAsBastion: schema.BoolAttribute{Description: "Use the provider's connection as a bastion host", Optional: true},
+	Host:                       schema.StringAttribute{Description: "Override the provider's host configuration", Optional: true},
+	User:                       schema.StringAttribute{Description: "Override the provider's user configuration", Optional: true},
+	Password:                   schema.StringAttribute{Description: "Override the provider's password configuration", Optional: true, Sensitive: true},
+	PrivateKey:                 schema.StringAttribute{Description: "Override the provider's private key configuration", Optional: true, Sensitive: true},
+	PrivateKeyPassphrase:       schema.StringAttribute{Description: "Override the provider's passphrase for an encrypted private key", Optional: true, Sensitive: true},
+	PrivateKeyPath:             schema.StringAttribute{Description: "Override the provider's private key with the key read from this local file at connect time (e.g. '~/.ssh/id_ed25519'). Only the path is stored in state", Optional: true, Validators: privateKeyPathValidators},
+	PasswordFile:               schema.StringAttribute{Description: "Override the provider's password with the password read from this local file at connect time. Only the path is stored in state", Optional: true, Validators: passwordFileValidators},
+	KeyboardInteractiveAnswers: schema.MapAttribute{Description: "Override the provider's static answers to keyboard-interactive prompts, keyed by a case-insensitive substring of the prompt", Optional: true, Sensitive: true, ElementType: types.StringType},
+	TOTPSecret:                 schema.StringAttribute{Description: "Override the provider's base32 TOTP secret used to answer one-time password prompts", Optional: true, Sensitive: true},
+	Certificate:                schema.StringAttribute{Description: "Override the provider's OpenSSH user certificate, signed by a CA trusted by the host", Optional: true},
+	Port:                       schema.Int64Attribute{Description: "The port number to connect to", Optional: true, Validators: portValidators},
+	ProxyCommand:               schema.StringAttribute{Description: "Override the provider's local command used to reach the host, whose stdin and stdout carry the SSH connection. The tokens %h, %p and %r are replaced by the host, port and user", Optional: true},
+	Proxy: schema.SingleNestedAttribute{
+		Description: "Override the provider's SOCKS5 or HTTP CONNECT proxy used to reach the host",
+		Optional:    true,
+		Attributes:  sshProxyAttributes,
+	},
+	Agent:                   schema.BoolAttribute{Description: "Authenticate using the identities of the running ssh-agent", Optional: true},
+	AgentSocket:             schema.StringAttribute{Description: "Path to the ssh-agent socket. Defaults to SSH_AUTH_SOCK", Optional: true},
+	AgentIdentity:           schema.StringAttribute{Description: "Only use the ssh-agent identity matching this comment, SHA256 fingerprint or public key", Optional: true},
+	KnownHostsFiles:         schema.ListAttribute{Description: "Override the provider's known_hosts files used to verify host keys", Optional: true, ElementType: types.StringType},
+	HostKeys:                schema.ListAttribute{Description: "Pinned host public keys (authorized_keys format) or SHA256 fingerprints the host must present", Optional: true, ElementType: types.StringType},
+	StrictHostKeyChecking:   schema.StringAttribute{Description: "Override the provider's host key checking policy ('strict', 'accept-new' or 'off')", Optional: true, Validators: hostKeyPolicyValidators},
+	ConnectTimeout:          schema.StringAttribute{Description: "Override the provider's maximum time to establish the connection, including the SSH handshake (e.g. '30s')", Optional: true, Validators: durationValidators},
+	ConnectRetries:          schema.Int64Attribute{Description: "Override the provider's number of times a failed connection attempt is retried", Optional: true, Validators: []validator.Int64{int64validator.AtLeast(0)}},
+	ConnectRetryTimeout:     schema.StringAttribute{Description: "Override the provider's maximum time spent retrying failed connection attempts (e.g. '5m')", Optional: true, Validators: durationValidators},
+	ConnectRetryInterval:    schema.StringAttribute{Description: "Override the provider's delay before the first retry of a failed connection attempt", Optional: true, Validators: durationValidators},
+	ConnectRetryMaxInterval: schema.StringAttribute{Description: "Override the provider's maximum delay between retries of failed connection attempts", Optional: true, Validators: durationValidators},
+	KeepaliveInterval:       schema.StringAttribute{Description: "Override the provider's interval between keepalive requests sent over an idle connection", Optional: true, Validators: durationValidators},
+	KeepaliveCountMax:       schema.Int64Attribute{Description: "Override the provider's number of unanswered keepalive requests after which the connection is closed", Optional: true, Validators: []validator.Int64{int64validator.AtLeast(1)}},
+	Ciphers:                 schema.ListAttribute{Description: "Override the provider's ciphers allowed for the connection, in order of preference", Optional: true, ElementType: types.StringType, Validators: cipherValidators},
+	KeyExchanges:            schema.ListAttribute{Description: "Override the provider's key exchange algorithms allowed for the connection, in order of preference", Optional: true, ElementType: types.StringType, Validators: keyExchangeValidators},
+	MACs:                    schema.ListAttribute{Description: "Override the provider's MAC algorithms allowed for the connection, in order of preference", Optional: true, ElementType: types.StringType, Validators: macValidators},
+	HostKeyAlgorithms:       schema.ListAttribute{Description: "Override the provider's host key algorithms accepted from the server, in order of preference", Optional: true, ElementType: types.StringType, Validators: hostKeyAlgorithmValidators},
+	UseProviderAsBastion:    schema.BoolAttribute{Description: "Use the provider's connection as a bastion host", Optional: true},
+	ConnectionName:          schema.StringAttribute{Description: "Name of the provider connection to use. The connection attributes set here are applied on top of it, and a bastion or jump hosts set here replace its own", Optional: true},
 	Bastion: schema.SingleNestedAttribute{
 		Description: "Bastion host configuration",
 		Optional:    true,
-		Attributes: map[string]schema.Attribute{
-			"host":        schema.StringAttribute{Description: "The hostname or IP address of the bastion host", Required: true},
-			"port":        schema.Int64Attribute{Description: "The port number of the bastion host", Optional: true},
-			"user":        schema.StringAttribute{Description: "The username for bastion host authentication", Required: true},
-			"password":    schema.StringAttribute{Description: "The password for bastion host authentication", Optional: true, Sensitive: true},
-			"private_key": schema.StringAttribute{Description: "The private key for bastion host authentication", Optional: true, Sensitive: true},
+		Attributes:  sshBastionAttributes,
+	},
+	JumpHosts: schema.ListNestedAttribute{
+		Description: "Ordered list of jump hosts, dialed hop by hop after the bastion (if any) to reach the target host",
+		Optional:    true,
+		NestedObject: schema.NestedAttributeObject{
+			Attributes: sshBastionAttributes,
 		},
 	},
 }
 
+// sshBastionAttributes are the attributes of a bastion or jump host
+var sshBastionAttributes = map[string]schema.Attribute{
+	"host":          schema.StringAttribute{Description: "The hostname or IP address of the bastion host", Required: true},
+	"port":          schema.Int64Attribute{Description: "The port number of the bastion host", Optional: true, Validators: portValidators},
+	"proxy_command": schema.StringAttribute{Description: "Local command used to reach the bastion host, whose stdin and stdout carry the SSH connection. The tokens %h, %p and %r are replaced by the host, port and user", Optional: true},
+	"proxy": schema.SingleNestedAttribute{
+		Description: "SOCKS5 or HTTP CONNECT proxy used to reach the bastion host",
+		Optional:    true,
+		Attributes:  sshProxyAttributes,
+	},
+	"user":                         schema.StringAttribute{Description: "The username for bastion host authentication", Required: true},
+	"password":                     schema.StringAttribute{Description: "The password for bastion host authentication", Optional: true, Sensitive: true},
+	"private_key":                  schema.StringAttribute{Description: "The private key for bastion host authentication", Optional: true, Sensitive: true},
+	"private_key_passphrase":       schema.StringAttribute{Description: "The passphrase for the encrypted bastion host private key", Optional: true, Sensitive: true},
+	"private_key_path":             schema.StringAttribute{Description: "Path of a local file holding the private key for bastion host authentication, read at connect time", Optional: true, Validators: privateKeyPathValidators},
+	"password_file":                schema.StringAttribute{Description: "Path of a local file holding the password for bastion host authentication, read at connect time", Optional: true, Validators: passwordFileValidators},
+	"keyboard_interactive_answers": schema.MapAttribute{Description: "Static answers to keyboard-interactive prompts of the bastion host, keyed by a case-insensitive substring of the prompt", Optional: true, Sensitive: true, ElementType: types.StringType},
+	"totp_secret":                  schema.StringAttribute{Description: "The base32 TOTP secret used to answer one-time password prompts of the bastion host", Optional: true, Sensitive: true},
+	"certificate":                  schema.StringAttribute{Description: "The OpenSSH user certificate for bastion host authentication", Optional: true},
+	"agent":                        schema.BoolAttribute{Description: "Authenticate to the bastion host using the identities of the running ssh-agent", Optional: true},
+	"agent_socket":                 schema.StringAttribute{Description: "Path to the ssh-agent socket used for the bastion host. Defaults to SSH_AUTH_SOCK", Optional: true},
+	"agent_identity":               schema.StringAttribute{Description: "Only use the ssh-agent identity matching this comment, SHA256 fingerprint or public key for the bastion host", Optional: true},
+	"known_hosts_files":            schema.ListAttribute{Description: "The known_hosts files used to verify the bastion host key", Optional: true, ElementType: types.StringType},
+	"host_keys":                    schema.ListAttribute{Description: "Pinned public keys or SHA256 fingerprints the bastion host must present", Optional: true, ElementType: types.StringType},
+	"strict_host_key_checking":     schema.StringAttribute{Description: "The host key checking policy for the bastion host ('strict', 'accept-new' or 'off')", Optional: true, Validators: hostKeyPolicyValidators},
+	"connect_timeout":              schema.StringAttribute{Description: "Maximum time to establish the connection to the bastion host, including the SSH handshake (e.g. '30s')", Optional: true, Validators: durationValidators},
+	"connect_retries":              schema.Int64Attribute{Description: "Number of times a failed connection attempt to the bastion host is retried", Optional: true, Validators: []validator.Int64{int64validator.AtLeast(0)}},
+	"connect_retry_timeout":        schema.StringAttribute{Description: "Maximum time spent retrying failed connection attempts to the bastion host (e.g. '5m')", Optional: true, Validators: durationValidators},
+	"connect_retry_interval":       schema.StringAttribute{Description: "Delay before the first retry of a failed connection attempt to the bastion host", Optional: true, Validators: durationValidators},
+	"connect_retry_max_interval":   schema.StringAttribute{Description: "Maximum delay between retries of failed connection attempts to the bastion host", Optional: true, Validators: durationValidators},
+	"keepalive_interval":           schema.StringAttribute{Description: "Interval between keepalive requests sent to the bastion host", Optional: true, Validators: durationValidators},
+	"keepalive_count_max":          schema.Int64Attribute{Description: "Number of unanswered keepalive requests after which the connection to the bastion host is closed", Optional: true, Validators: []validator.Int64{int64validator.AtLeast(1)}},
+	"ciphers":                      schema.ListAttribute{Description: "Ciphers allowed for the connection to the bastion host, in order of preference", Optional: true, ElementType: types.StringType, Validators: cipherValidators},
+	"key_exchanges":                schema.ListAttribute{Description: "Key exchange algorithms allowed for the connection to the bastion host, in order of preference", Optional: true, ElementType: types.StringType, Validators: keyExchangeValidators},
+	"macs":                         schema.ListAttribute{Description: "MAC algorithms allowed for the connection to the bastion host, in order of preference", Optional: true, ElementType: types.StringType, Validators: macValidators},
+	"host_key_algorithms":          schema.ListAttribute{Description: "Host key algorithms accepted from the bastion host, in order of preference", Optional: true, ElementType: types.StringType, Validators: hostKeyAlgorithmValidators},
+}
+
+// Supported proxy types
+const (
+	proxyTypeSOCKS5 = "socks5"
+	proxyTypeHTTP   = "http"
+)
+
+var SSHProxySchema = struct {
+	Type     schema.StringAttribute
+	Host     schema.StringAttribute
+	Port     schema.Int64Attribute
+	Username schema.StringAttribute
+	Password schema.StringAttribute
+}{
+	Type:     schema.StringAttribute{Description: "The proxy protocol, either 'socks5' or 'http' (HTTP CONNECT)", Required: true, Validators: []validator.String{stringvalidator.OneOf(proxyTypeSOCKS5, proxyTypeHTTP)}},
+	Host:     schema.StringAttribute{Description: "The hostname or IP address of the proxy", Required: true},
+	Port:     schema.Int64Attribute{Description: "The port number of the proxy. Defaults to 1080 for SOCKS5 and 8080 for HTTP", Optional: true, Validators: portValidators},
+	Username: schema.StringAttribute{Description: "The username for proxy authentication", Optional: true},
+	Password: schema.StringAttribute{Description: "The password for proxy authentication", Optional: true, Sensitive: true},
+}
+
+// sshProxyAttributes are the attributes of a proxy
+var sshProxyAttributes = map[string]schema.Attribute{
+	"type":     SSHProxySchema.Type,
+	"host":     SSHProxySchema.Host,
+	"port":     SSHProxySchema.Port,
+	"username": SSHProxySchema.Username,
+	"password": SSHProxySchema.Password,
+}
+
+// Supported privilege escalation methods
+const (
+	becomeMethodSudo = "sudo"
+	becomeMethodSu   = "su"
+	becomeMethodDoas = "doas"
+)
+
+// becomeMethodValidators restricts privilege escalation methods to the supported values
+var becomeMethodValidators = []validator.String{
+	stringvalidator.OneOf(becomeMethodSudo, becomeMethodSu, becomeMethodDoas),
+}
+
+var SSHBecomeSchema = struct {
+	Become         schema.BoolAttribute
+	BecomeUser     schema.StringAttribute
+	BecomeMethod   schema.StringAttribute
+	BecomePassword schema.StringAttribute
+}{
+	Become:         schema.BoolAttribute{Description: "Override the provider's privilege escalation: whether commands and file operations run as become_user", Optional: true},
+	BecomeUser:     schema.StringAttribute{Description: "Override the provider's user commands and file operations run as", Optional: true},
+	BecomeMethod:   schema.StringAttribute{Description: "Override the provider's privilege escalation method ('sudo', 'su' or 'doas')", Optional: true, Validators: becomeMethodValidators},
+	BecomePassword: schema.StringAttribute{Description: "Override the provider's password answering the prompt of the privilege escalation method", Optional: true, Sensitive: true},
+}
+
+// hostKeyPolicyValidators restricts host key checking policies to the supported values
+var hostKeyPolicyValidators = []validator.String{
+	stringvalidator.OneOf(hostKeyPolicyStrict, hostKeyPolicyAcceptNew, hostKeyPolicyOff),
+}
+
+// privateKeyPathValidators and passwordFileValidators keep the file and the inline value
+// from being set together
+var (
+	privateKeyPathValidators = []validator.String{stringvalidator.ConflictsWith(path.MatchRelative().AtParent().AtName("private_key"))}
+	passwordFileValidators   = []validator.String{stringvalidator.ConflictsWith(path.MatchRelative().AtParent().AtName("password"))}
+)
+
+// portValidators restricts ports to the valid TCP port range
+var portValidators = []validator.Int64{int64validator.Between(1, 65535)}
+
+// durationValidators requires strings to be valid Go durations such as '30s' or '5m'
+var durationValidators = []validator.String{durationValidator{}}
+
+// durationValidator validates that a string parses with time.ParseDuration
+type durationValidator struct{}
+
+func (v durationValidator) Description(_ context.Context) string {
+	return "value must be a duration such as '30s', '5m' or '1h30m'"
+}
+
+func (v durationValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v durationValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if duration, err := time.ParseDuration(req.ConfigValue.ValueString()); err != nil || duration < 0 {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid duration",
+			fmt.Sprintf("The %s %q is not valid: %s.", req.Path, req.ConfigValue.ValueString(), v.Description(ctx)),
+		)
+	}
+}
+
 // Common model for SSH connection configuration
 type SSHConnectionModel struct {
-	Host       types.String `tfsdk:"host"`
-	User       types.String `tfsdk:"user"`
-	Password   types.String `tfsdk:"password"`
-	PrivateKey types.String `tfsdk:"private_key"`
-	Port       types.Int64  `tfsdk:"port"`
+	Host                       types.String   `tfsdk:"host"`
+	User                       types.String   `tfsdk:"user"`
+	Password                   types.String   `tfsdk:"password"`
+	PrivateKey                 types.String   `tfsdk:"private_key"`
+	PrivateKeyPassphrase       types.String   `tfsdk:"private_key_passphrase"`
+	PrivateKeyPath             types.String   `tfsdk:"private_key_path"`
+	PasswordFile               types.String   `tfsdk:"password_file"`
+	KeyboardInteractiveAnswers types.Map      `tfsdk:"keyboard_interactive_answers"`
+	TOTPSecret                 types.String   `tfsdk:"totp_secret"`
+	Certificate                types.String   `tfsdk:"certificate"`
+	Port                       types.Int64    `tfsdk:"port"`
+	ProxyCommand               types.String   `tfsdk:"proxy_command"`
+	Proxy                      *SSHProxyModel `tfsdk:"proxy"`
+	Agent                      types.Bool     `tfsdk:"agent"`
+	AgentSocket                types.String   `tfsdk:"agent_socket"`
+	AgentIdentity              types.String   `tfsdk:"agent_identity"`
+	KnownHostsFiles            types.List     `tfsdk:"known_hosts_files"`
+	HostKeys                   types.List     `tfsdk:"host_keys"`
+	StrictHostKeyChecking      types.String   `tfsdk:"strict_host_key_checking"`
+	ConnectTimeout             types.String   `tfsdk:"connect_timeout"`
+	ConnectRetries             types.Int64    `tfsdk:"connect_retries"`
+	ConnectRetryTimeout        types.String   `tfsdk:"connect_retry_timeout"`
+	ConnectRetryInterval       types.String   `tfsdk:"connect_retry_interval"`
+	ConnectRetryMaxInterval    types.String   `tfsdk:"connect_retry_max_interval"`
+	KeepaliveInterval          types.String   `tfsdk:"keepalive_interval"`
+	KeepaliveCountMax          types.Int64    `tfsdk:"keepalive_count_max"`
+	Ciphers                    types.List     `tfsdk:"ciphers"`
+	KeyExchanges               types.List     `tfsdk:"key_exchanges"`
+	MACs                       types.List     `tfsdk:"macs"`
+	HostKeyAlgorithms          types.List     `tfsdk:"host_key_algorithms"`
 }
 
 type SSHConnectionConfig struct {
-	Host       *string
-	User       *string
-	Password   *string
-	PrivateKey *string
-	Port       *int64
+	Host                       *string
+	User                       *string
+	Password                   *string
+	PrivateKey                 *string
+	PrivateKeyPassphrase       *string
+	PrivateKeyPath             *string
+	PasswordFile               *string
+	KeyboardInteractiveAnswers map[string]string
+	TOTPSecret                 *string
+	Certificate                *string
+	Port                       *int64
+	ProxyCommand               *string
+	Proxy                      *SSHProxyConfig
+	Agent                      *bool
+	AgentSocket                *string
+	AgentIdentity              *string
+	KnownHostsFiles            []string
+	HostKeys                   []string
+	StrictHostKeyChecking      *string
+	ConnectTimeout             *string
+	ConnectRetries             *int64
+	ConnectRetryTimeout        *string
+	ConnectRetryInterval       *string
+	ConnectRetryMaxInterval    *string
+	KeepaliveInterval          *string
+	KeepaliveCountMax          *int64
+	Ciphers                    []string
+	KeyExchanges               []string
+	MACs                       []string
+	HostKeyAlgorithms          []string
+
+	// Connection names the provider connection this config is applied on top of
+	Connection *string
+
+	// sshConfigApplied marks configs already resolved from the OpenSSH client configuration
+	sshConfigApplied bool
 }
 
 func (m *SSHConnectionModel) toConfig() *SSHConnectionConfig {
@@ -74,10 +330,282 @@ func (m *SSHConnectionModel) toConfig() *SSHConnectionConfig {
 		value := m.PrivateKey.ValueString()
 		config.PrivateKey = &value
 	}
+	if !m.PrivateKeyPassphrase.IsNull() {
+		value := m.PrivateKeyPassphrase.ValueString()
+		config.PrivateKeyPassphrase = &value
+	}
+	if !m.PrivateKeyPath.IsNull() {
+		value := m.PrivateKeyPath.ValueString()
+		config.PrivateKeyPath = &value
+	}
+	if !m.PasswordFile.IsNull() {
+		value := m.PasswordFile.ValueString()
+		config.PasswordFile = &value
+	}
+	if !m.KeyboardInteractiveAnswers.IsNull() {
+		config.KeyboardInteractiveAnswers = mapValueStrings(m.KeyboardInteractiveAnswers)
+	}
+	if !m.TOTPSecret.IsNull() {
+		value := m.TOTPSecret.ValueString()
+		config.TOTPSecret = &value
+	}
+	if !m.Certificate.IsNull() {
+		value := m.Certificate.ValueString()
+		config.Certificate = &value
+	}
 	if !m.Port.IsNull() {
 		value := m.Port.ValueInt64()
 		config.Port = &value
 	}
+	if !m.ProxyCommand.IsNull() {
+		value := m.ProxyCommand.ValueString()
+		config.ProxyCommand = &value
+	}
+	config.Proxy = m.Proxy.toConfig()
+	if !m.Agent.IsNull() {
+		value := m.Agent.ValueBool()
+		config.Agent = &value
+	}
+	if !m.AgentSocket.IsNull() {
+		value := m.AgentSocket.ValueString()
+		config.AgentSocket = &value
+	}
+	if !m.AgentIdentity.IsNull() {
+		value := m.AgentIdentity.ValueString()
+		config.AgentIdentity = &value
+	}
+	if !m.KnownHostsFiles.IsNull() {
+		config.KnownHostsFiles = listValueStrings(m.KnownHostsFiles)
+	}
+	if !m.HostKeys.IsNull() {
+		config.HostKeys = listValueStrings(m.HostKeys)
+	}
+	if !m.StrictHostKeyChecking.IsNull() {
+		value := m.StrictHostKeyChecking.ValueString()
+		config.StrictHostKeyChecking = &value
+	}
+	if !m.ConnectTimeout.IsNull() {
+		value := m.ConnectTimeout.ValueString()
+		config.ConnectTimeout = &value
+	}
+	if !m.ConnectRetries.IsNull() {
+		value := m.ConnectRetries.ValueInt64()
+		config.ConnectRetries = &value
+	}
+	if !m.ConnectRetryTimeout.IsNull() {
+		value := m.ConnectRetryTimeout.ValueString()
+		config.ConnectRetryTimeout = &value
+	}
+	if !m.ConnectRetryInterval.IsNull() {
+		value := m.ConnectRetryInterval.ValueString()
+		config.ConnectRetryInterval = &value
+	}
+	if !m.ConnectRetryMaxInterval.IsNull() {
+		value := m.ConnectRetryMaxInterval.ValueString()
+		config.ConnectRetryMaxInterval = &value
+	}
+	if !m.KeepaliveInterval.IsNull() {
+		value := m.KeepaliveInterval.ValueString()
+		config.KeepaliveInterval = &value
+	}
+	if !m.KeepaliveCountMax.IsNull() {
+		value := m.KeepaliveCountMax.ValueInt64()
+		config.KeepaliveCountMax = &value
+	}
+	if !m.Ciphers.IsNull() {
+		config.Ciphers = listValueStrings(m.Ciphers)
+	}
+	if !m.KeyExchanges.IsNull() {
+		config.KeyExchanges = listValueStrings(m.KeyExchanges)
+	}
+	if !m.MACs.IsNull() {
+		config.MACs = listValueStrings(m.MACs)
+	}
+	if !m.HostKeyAlgorithms.IsNull() {
+		config.HostKeyAlgorithms = listValueStrings(m.HostKeyAlgorithms)
+	}
 
 	return config
 }
+
+// SSHProxyModel describes a SOCKS5 or HTTP CONNECT proxy
+type SSHProxyModel struct {
+	Type     types.String `tfsdk:"type"`
+	Host     types.String `tfsdk:"host"`
+	Port     types.Int64  `tfsdk:"port"`
+	Username types.String `tfsdk:"username"`
+	Password types.String `tfsdk:"password"`
+}
+
+type SSHProxyConfig struct {
+	Type     string
+	Host     string
+	Port     *int64
+	Username *string
+	Password *string
+}
+
+func (m *SSHProxyModel) toConfig() *SSHProxyConfig {
+	if m == nil {
+		return nil
+	}
+
+	config := &SSHProxyConfig{
+		Type: m.Type.ValueString(),
+		Host: m.Host.ValueString(),
+	}
+	if !m.Port.IsNull() {
+		value := m.Port.ValueInt64()
+		config.Port = &value
+	}
+	if !m.Username.IsNull() {
+		value := m.Username.ValueString()
+		config.Username = &value
+	}
+	if !m.Password.IsNull() {
+		value := m.Password.ValueString()
+		config.Password = &value
+	}
+
+	return config
+}
+
+// SSHBecomeModel holds the privilege escalation settings of commands and file operations
+type SSHBecomeModel struct {
+	Become         types.Bool   `tfsdk:"become"`
+	BecomeUser     types.String `tfsdk:"become_user"`
+	BecomeMethod   types.String `tfsdk:"become_method"`
+	BecomePassword types.String `tfsdk:"become_password"`
+}
+
+// withDefaults returns a copy of the config where connection settings that are not
+// specific to a single host are inherited from defaults when left unset.
+func (c SSHConnectionConfig) withDefaults(defaults *SSHConnectionConfig) SSHConnectionConfig {
+	if defaults == nil {
+		return c
+	}
+
+	if c.KnownHostsFiles == nil {
+		c.KnownHostsFiles = defaults.KnownHostsFiles
+	}
+	if c.StrictHostKeyChecking == nil {
+		c.StrictHostKeyChecking = defaults.StrictHostKeyChecking
+	}
+	if c.Proxy == nil {
+		c.Proxy = defaults.Proxy
+	}
+	if c.ConnectTimeout == nil {
+		c.ConnectTimeout = defaults.ConnectTimeout
+	}
+	if c.ConnectRetries == nil {
+		c.ConnectRetries = defaults.ConnectRetries
+	}
+	if c.ConnectRetryTimeout == nil {
+		c.ConnectRetryTimeout = defaults.ConnectRetryTimeout
+	}
+	if c.ConnectRetryInterval == nil {
+		c.ConnectRetryInterval = defaults.ConnectRetryInterval
+	}
+	if c.ConnectRetryMaxInterval == nil {
+		c.ConnectRetryMaxInterval = defaults.ConnectRetryMaxInterval
+	}
+	if c.KeepaliveInterval == nil {
+		c.KeepaliveInterval = defaults.KeepaliveInterval
+	}
+	if c.KeepaliveCountMax == nil {
+		c.KeepaliveCountMax = defaults.KeepaliveCountMax
+	}
+	if c.Ciphers == nil {
+		c.Ciphers = defaults.Ciphers
+	}
+	if c.KeyExchanges == nil {
+		c.KeyExchanges = defaults.KeyExchanges
+	}
+	if c.MACs == nil {
+		c.MACs = defaults.MACs
+	}
+	if c.HostKeyAlgorithms == nil {
+		c.HostKeyAlgorithms = defaults.HostKeyAlgorithms
+	}
+
+	return c
+}
+
+// withOverrides returns a copy of the config where every setting configured in overrides
+// replaces its value, including credentials
+func (c SSHConnectionConfig) withOverrides(overrides SSHConnectionConfig) SSHConnectionConfig {
+	result := reflect.ValueOf(&c).Elem()
+	values := reflect.ValueOf(overrides)
+	for i := 0; i < values.NumField(); i++ {
+		if !values.Type().Field(i).IsExported() {
+			continue
+		}
+		switch value := values.Field(i); value.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+			if !value.IsNil() {
+				result.Field(i).Set(value)
+			}
+		}
+	}
+
+	// A key or password replaces the file it would otherwise be read from, and vice versa
+	if overrides.PrivateKey != nil && overrides.PrivateKeyPath == nil {
+		c.PrivateKeyPath = nil
+	}
+	if overrides.PrivateKeyPath != nil && overrides.PrivateKey == nil {
+		c.PrivateKey = nil
+	}
+	if overrides.Password != nil && overrides.PasswordFile == nil {
+		c.PasswordFile = nil
+	}
+	if overrides.PasswordFile != nil && overrides.Password == nil {
+		c.Password = nil
+	}
+	return c
+}
+
+// connectionConfig returns the connection settings of a resource or data source, naming
+// the provider connection they are applied on top of, if any
+func connectionConfig(connection types.String, model *SSHConnectionModel) SSHConnectionConfig {
+	config := *model.toConfig()
+	if !connection.IsNull() {
+		name := connection.ValueString()
+		config.Connection = &name
+	}
+	return config
+}
+
+// jumpHostConfigs returns the hops used to reach a host, starting with the bastion
+// followed by the jump hosts in order
+func jumpHostConfigs(bastion *SSHConnectionModel, jumpHosts []SSHConnectionModel) []SSHConnectionConfig {
+	var configs []SSHConnectionConfig
+	if bastion != nil {
+		configs = append(configs, *bastion.toConfig())
+	}
+	for i := range jumpHosts {
+		configs = append(configs, *jumpHosts[i].toConfig())
+	}
+	return configs
+}
+
+// listValueStrings converts a list of strings into a Go slice, skipping null and unknown elements
+func listValueStrings(list types.List) []string {
+	values := make([]string, 0, len(list.Elements()))
+	for _, element := range list.Elements() {
+		if value, ok := element.(types.String); ok && !value.IsNull() && !value.IsUnknown() {
+			values = append(values, value.ValueString())
+		}
+	}
+	return values
+}
+
+// mapValueStrings converts a map of strings into a Go map, skipping null and unknown elements
+func mapValueStrings(m types.Map) map[string]string {
+	values := make(map[string]string, len(m.Elements()))
+	for key, element := range m.Elements() {
+		if value, ok := element.(types.String); ok && !value.IsNull() && !value.IsUnknown() {
+			values[key] = value.ValueString()
+		}
+	}
+	return values
+}
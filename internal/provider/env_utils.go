@@ -0,0 +1,212 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// envPrefix is the prefix of the environment variables providing defaults for the
+// provider attributes, e.g. SSH_HOST for host and SSH_BASTION_USER for bastion.user
+const envPrefix = "SSH_"
+
+// envFileSuffixes maps attributes holding key material to the suffix of the environment
+// variable pointing at a file to read it from, e.g. SSH_CERTIFICATE_PATH for certificate.
+// Private keys have their own private_key_path attribute.
+var envFileSuffixes = map[string]string{
+	"certificate": "_PATH",
+}
+
+// envVarName returns the environment variable of an attribute path such as bastion.proxy.host
+func envVarName(attrPath string) string {
+	name := envPrefix + strings.ToUpper(strings.ReplaceAll(attrPath, ".", "_"))
+	// Avoid stuttering for attributes that already start with ssh_, e.g. SSH_CONFIG_FILE
+	return strings.Replace(name, envPrefix+envPrefix, envPrefix, 1)
+}
+
+// applyEnvDefaults fills the null attributes of model, a pointer to a struct with tfsdk
+// tags, from their environment variables. Nested objects that are not configured are only
+// created when one of their environment variables is set. The source of every attribute
+// that ends up with a value is recorded in sources, keyed by attribute path.
+func applyEnvDefaults(model any, attrPrefix string, sources map[string]string) error {
+	_, err := applyEnvDefaultsTo(reflect.ValueOf(model).Elem(), attrPrefix, sources)
+	return err
+}
+
+// applyEnvDefaultsTo fills the struct value and reports whether any attribute was set
+// from the environment
+func applyEnvDefaultsTo(value reflect.Value, attrPrefix string, sources map[string]string) (bool, error) {
+	fromEnv := false
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		structField := value.Type().Field(i)
+
+		// Embedded models share the attributes of the enclosing one
+		if structField.Anonymous {
+			set, err := applyEnvDefaultsTo(field, attrPrefix, sources)
+			if err != nil {
+				return false, err
+			}
+			fromEnv = fromEnv || set
+			continue
+		}
+
+		tag := structField.Tag.Get("tfsdk")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		attrPath := attrPrefix + tag
+
+		switch current := field.Interface().(type) {
+		case attr.Value:
+			if !current.IsNull() {
+				sources[attrPath] = "config"
+				continue
+			}
+			newValue, source, err := envValue(attrPath, current)
+			if err != nil {
+				return false, err
+			}
+			if newValue == nil {
+				continue
+			}
+			field.Set(reflect.ValueOf(newValue))
+			sources[attrPath] = source
+			fromEnv = true
+		default:
+			// Nested objects such as the bastion and the proxy
+			if field.Kind() != reflect.Pointer || field.Type().Elem().Kind() != reflect.Struct {
+				continue
+			}
+			nested := field
+			if field.IsNil() {
+				nested = reflect.New(field.Type().Elem())
+			}
+			set, err := applyEnvDefaultsTo(nested.Elem(), attrPath+".", sources)
+			if err != nil {
+				return false, err
+			}
+			if set && field.IsNil() {
+				field.Set(nested)
+			}
+			fromEnv = fromEnv || set
+		}
+	}
+	dropConflictingCredentials(value, attrPrefix, sources)
+	return fromEnv, nil
+}
+
+// credentialFilePairs lists the attributes holding credentials along with the attribute
+// reading them from a file, of which only one can be set
+var credentialFilePairs = [][2]string{
+	{"private_key", "private_key_path"},
+	{"password", "password_file"},
+}
+
+// dropConflictingCredentials unsets the credentials taken from environment variables
+// when the other attribute of their pair is set as well. The configuration wins over the
+// environment, and between two environment variables the credential wins over its file.
+// Pairs set in the configuration alone are left to the attribute validators.
+func dropConflictingCredentials(value reflect.Value, attrPrefix string, sources map[string]string) {
+	fields := make(map[string]reflect.Value)
+	for i := 0; i < value.NumField(); i++ {
+		if tag := value.Type().Field(i).Tag.Get("tfsdk"); tag != "" {
+			fields[tag] = value.Field(i)
+		}
+	}
+
+	for _, pair := range credentialFilePairs {
+		credential, file := fields[pair[0]], fields[pair[1]]
+		if !credential.IsValid() || !file.IsValid() {
+			continue
+		}
+		credentialSource, fileSource := sources[attrPrefix+pair[0]], sources[attrPrefix+pair[1]]
+		if credentialSource == "" || fileSource == "" {
+			continue
+		}
+		switch {
+		case fileSource != "config":
+			file.Set(reflect.Zero(file.Type()))
+			delete(sources, attrPrefix+pair[1])
+		case credentialSource != "config":
+			credential.Set(reflect.Zero(credential.Type()))
+			delete(sources, attrPrefix+pair[0])
+		}
+	}
+}
+
+// envValue returns the value of an attribute from its environment variable, or nil when it
+// is not set. Lists are comma-separated and maps are JSON objects.
+func envValue(attrPath string, current attr.Value) (attr.Value, string, error) {
+	name := envVarName(attrPath)
+	raw, ok := os.LookupEnv(name)
+	if !ok || raw == "" {
+		// Key material can also be read from a file
+		tag := attrPath[strings.LastIndex(attrPath, ".")+1:]
+		suffix, isFile := envFileSuffixes[tag]
+		if !isFile {
+			return nil, "", nil
+		}
+		pathName := name + suffix
+		file := os.Getenv(pathName)
+		if file == "" {
+			return nil, "", nil
+		}
+		content, err := os.ReadFile(expandPath(file))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %s from %s: %w", attrPath, pathName, err)
+		}
+		return types.StringValue(string(content)), "env " + pathName, nil
+	}
+
+	source := "env " + name
+	switch current.(type) {
+	case types.String:
+		return types.StringValue(raw), source, nil
+	case types.Int64:
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid %s %q: expected an integer", name, raw)
+		}
+		return types.Int64Value(value), source, nil
+	case types.Bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid %s %q: expected true or false", name, raw)
+		}
+		return types.BoolValue(value), source, nil
+	case types.List:
+		var elements []attr.Value
+		for _, element := range strings.Split(raw, ",") {
+			if element = strings.TrimSpace(element); element != "" {
+				elements = append(elements, types.StringValue(element))
+			}
+		}
+		value, diags := types.ListValue(types.StringType, elements)
+		if diags.HasError() {
+			return nil, "", fmt.Errorf("invalid %s: %v", name, diags)
+		}
+		return value, source, nil
+	case types.Map:
+		var elements map[string]string
+		if err := json.Unmarshal([]byte(raw), &elements); err != nil {
+			return nil, "", fmt.Errorf("invalid %s: expected a JSON object of strings: %w", name, err)
+		}
+		values := make(map[string]attr.Value, len(elements))
+		for key, element := range elements {
+			values[key] = types.StringValue(element)
+		}
+		value, diags := types.MapValue(types.StringType, values)
+		if diags.HasError() {
+			return nil, "", fmt.Errorf("invalid %s: %v", name, diags)
+		}
+		return value, source, nil
+	}
+	return nil, "", nil
+}
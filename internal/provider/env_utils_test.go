@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestEnvValue(t *testing.T) {
+	certificateFile := filepath.Join(t.TempDir(), "id_ed25519-cert.pub")
+	if err := os.WriteFile(certificateFile, []byte("certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		attrPath   string
+		current    attr.Value
+		env        map[string]string
+		want       attr.Value
+		wantSource string
+		wantErr    string
+	}{
+		{name: "unset", attrPath: "host", current: types.StringNull()},
+		{name: "empty", attrPath: "host", current: types.StringNull(), env: map[string]string{"SSH_HOST": ""}},
+		{name: "string", attrPath: "host", current: types.StringNull(), env: map[string]string{"SSH_HOST": "example.com"}, want: types.StringValue("example.com"), wantSource: "env SSH_HOST"},
+		{name: "nested", attrPath: "bastion.proxy.host", current: types.StringNull(), env: map[string]string{"SSH_BASTION_PROXY_HOST": "proxy"}, want: types.StringValue("proxy"), wantSource: "env SSH_BASTION_PROXY_HOST"},
+		{name: "no stutter", attrPath: "ssh_config_file", current: types.StringNull(), env: map[string]string{"SSH_CONFIG_FILE": "~/.ssh/config"}, want: types.StringValue("~/.ssh/config"), wantSource: "env SSH_CONFIG_FILE"},
+		{name: "int64", attrPath: "port", current: types.Int64Null(), env: map[string]string{"SSH_PORT": "2222"}, want: types.Int64Value(2222), wantSource: "env SSH_PORT"},
+		{name: "invalid int64", attrPath: "port", current: types.Int64Null(), env: map[string]string{"SSH_PORT": "ssh"}, wantErr: `invalid SSH_PORT "ssh": expected an integer`},
+		{name: "bool", attrPath: "agent", current: types.BoolNull(), env: map[string]string{"SSH_AGENT": "true"}, want: types.BoolValue(true), wantSource: "env SSH_AGENT"},
+		{name: "invalid bool", attrPath: "agent", current: types.BoolNull(), env: map[string]string{"SSH_AGENT": "yes please"}, wantErr: `invalid SSH_AGENT "yes please": expected true or false`},
+		{
+			name: "list", attrPath: "ciphers", current: types.ListNull(types.StringType), env: map[string]string{"SSH_CIPHERS": "aes256-ctr, ,chacha20-poly1305@openssh.com"},
+			want:       types.ListValueMust(types.StringType, []attr.Value{types.StringValue("aes256-ctr"), types.StringValue("chacha20-poly1305@openssh.com")}),
+			wantSource: "env SSH_CIPHERS",
+		},
+		{
+			name: "map", attrPath: "keyboard_interactive_answers", current: types.MapNull(types.StringType), env: map[string]string{"SSH_KEYBOARD_INTERACTIVE_ANSWERS": `{"code": "1234"}`},
+			want:       types.MapValueMust(types.StringType, map[string]attr.Value{"code": types.StringValue("1234")}),
+			wantSource: "env SSH_KEYBOARD_INTERACTIVE_ANSWERS",
+		},
+		{name: "invalid map", attrPath: "keyboard_interactive_answers", current: types.MapNull(types.StringType), env: map[string]string{"SSH_KEYBOARD_INTERACTIVE_ANSWERS": "code=1234"}, wantErr: "expected a JSON object of strings"},
+		{name: "file", attrPath: "bastion.certificate", current: types.StringNull(), env: map[string]string{"SSH_BASTION_CERTIFICATE_PATH": certificateFile}, want: types.StringValue("certificate"), wantSource: "env SSH_BASTION_CERTIFICATE_PATH"},
+		{name: "value wins over file", attrPath: "certificate", current: types.StringNull(), env: map[string]string{"SSH_CERTIFICATE": "inline", "SSH_CERTIFICATE_PATH": certificateFile}, want: types.StringValue("inline"), wantSource: "env SSH_CERTIFICATE"},
+		{name: "missing file", attrPath: "certificate", current: types.StringNull(), env: map[string]string{"SSH_CERTIFICATE_PATH": filepath.Join(t.TempDir(), "missing")}, wantErr: "failed to read certificate from SSH_CERTIFICATE_PATH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			value, source, err := envValue(tt.attrPath, tt.current)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if value != nil {
+					t.Errorf("expected no value, got %s", value)
+				}
+				return
+			}
+			if value == nil || !value.Equal(tt.want) || source != tt.wantSource {
+				t.Errorf("expected %s from %q, got %v from %q", tt.want, tt.wantSource, value, source)
+			}
+		})
+	}
+}
+
+func TestApplyEnvDefaults(t *testing.T) {
+	t.Setenv("SSH_HOST", "env.example.com")
+	t.Setenv("SSH_USER", "deploy")
+	t.Setenv("SSH_BASTION_HOST", "bastion.example.com")
+	t.Setenv("SSH_BECOME", "true")
+	t.Setenv("SSH_PORT", "")
+	t.Setenv("SSH_BASTION_PROXY_TYPE", "")
+	t.Setenv("SSH_BASTION_PROXY_HOST", "")
+
+	config := SSHProviderModel{}
+	config.Host = types.StringValue("config.example.com")
+	sources := map[string]string{}
+	if err := applyEnvDefaults(&config, "", sources); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Host.ValueString() != "config.example.com" || sources["host"] != "config" {
+		t.Errorf("configured host was overridden: %s from %q", config.Host, sources["host"])
+	}
+	if config.User.ValueString() != "deploy" || sources["user"] != "env SSH_USER" {
+		t.Errorf("unexpected user %s from %q", config.User, sources["user"])
+	}
+	if !config.Become.ValueBool() || sources["become"] != "env SSH_BECOME" {
+		t.Errorf("embedded become model was not filled: %s from %q", config.Become, sources["become"])
+	}
+	if config.Bastion == nil || config.Bastion.Host.ValueString() != "bastion.example.com" || sources["bastion.host"] != "env SSH_BASTION_HOST" {
+		t.Errorf("bastion was not created from the environment: %+v", config.Bastion)
+	}
+	if config.Bastion != nil && config.Bastion.Proxy != nil {
+		t.Error("bastion proxy was created although none of its variables are set")
+	}
+	if !config.Port.IsNull() {
+		t.Errorf("port was set without its variable: %s", config.Port)
+	}
+}
+
+func TestApplyEnvDefaults_Credentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		config  func(config *SSHProviderModel)
+		check   func(config SSHProviderModel) bool
+		sources map[string]string
+	}{
+		{
+			name:    "private key in config ignores the path from env",
+			env:     map[string]string{"SSH_PRIVATE_KEY_PATH": "~/.ssh/id_ed25519"},
+			config:  func(config *SSHProviderModel) { config.PrivateKey = types.StringValue("key") },
+			check:   func(config SSHProviderModel) bool { return config.PrivateKeyPath.IsNull() },
+			sources: map[string]string{"private_key": "config"},
+		},
+		{
+			name:    "path in config ignores the private key from env",
+			env:     map[string]string{"SSH_PRIVATE_KEY": "key"},
+			config:  func(config *SSHProviderModel) { config.PrivateKeyPath = types.StringValue("~/.ssh/id_ed25519") },
+			check:   func(config SSHProviderModel) bool { return config.PrivateKey.IsNull() },
+			sources: map[string]string{"private_key_path": "config"},
+		},
+		{
+			name: "password from env wins over its file from env",
+			env:  map[string]string{"SSH_PASSWORD": "secret", "SSH_PASSWORD_FILE": "/run/secrets/password"},
+			check: func(config SSHProviderModel) bool {
+				return config.Password.ValueString() == "secret" && config.PasswordFile.IsNull()
+			},
+			sources: map[string]string{"password": "env SSH_PASSWORD"},
+		},
+		{
+			name: "bastion password in config ignores its file from env",
+			env:  map[string]string{"SSH_BASTION_PASSWORD_FILE": "/run/secrets/password"},
+			config: func(config *SSHProviderModel) {
+				config.Bastion = &SSHConnectionModel{Host: types.StringValue("bastion"), Password: types.StringValue("secret")}
+			},
+			check:   func(config SSHProviderModel) bool { return config.Bastion.PasswordFile.IsNull() },
+			sources: map[string]string{"bastion.host": "config", "bastion.password": "config"},
+		},
+		{
+			name: "both in config are kept for the validators",
+			config: func(config *SSHProviderModel) {
+				config.Password, config.PasswordFile = types.StringValue("secret"), types.StringValue("/run/secrets/password")
+			},
+			check:   func(config SSHProviderModel) bool { return !config.Password.IsNull() && !config.PasswordFile.IsNull() },
+			sources: map[string]string{"password": "config", "password_file": "config"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"SSH_PRIVATE_KEY", "SSH_PRIVATE_KEY_PATH", "SSH_PASSWORD", "SSH_PASSWORD_FILE", "SSH_BASTION_PASSWORD", "SSH_BASTION_PASSWORD_FILE"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			config := SSHProviderModel{}
+			if tt.config != nil {
+				tt.config(&config)
+			}
+			sources := map[string]string{}
+			if err := applyEnvDefaults(&config, "", sources); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(config) {
+				t.Errorf("unexpected credentials %+v", config.SSHConnectionModel)
+			}
+			for _, attrPath := range []string{"private_key", "private_key_path", "password", "password_file", "bastion.password", "bastion.password_file"} {
+				if sources[attrPath] != tt.sources[attrPath] {
+					t.Errorf("expected %s from %q, got %q", attrPath, tt.sources[attrPath], sources[attrPath])
+				}
+			}
+		})
+	}
+}
@@ -1,14 +1,48 @@
 package provider
 
 import (
+	"bytes"
+	"context"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"golang.org/x/crypto/ssh"
 )
 
+// remoteForwardConfigs converts the remote forwards of a model, applying the defaults
+func remoteForwardConfigs(forwards []SSHRemoteForwardModel) []RemoteForwardConfig {
+	var configs []RemoteForwardConfig
+	for _, forward := range forwards {
+		remoteHost, localHost := defaultTunnelLocalHost, defaultTunnelLocalHost
+		if !forward.RemoteHost.IsNull() {
+			remoteHost = forward.RemoteHost.ValueString()
+		}
+		if !forward.LocalHost.IsNull() {
+			localHost = forward.LocalHost.ValueString()
+		}
+		configs = append(configs, RemoteForwardConfig{
+			Remote: net.JoinHostPort(remoteHost, strconv.FormatInt(forward.RemotePort.ValueInt64(), 10)),
+			Local:  net.JoinHostPort(localHost, strconv.FormatInt(forward.LocalPort.ValueInt64(), 10)),
+		})
+	}
+	return configs
+}
+
 func generateExecID(command string, timestamp time.Time) string {
 	h := md5.New()
 	h.Write([]byte(command))
@@ -16,27 +50,356 @@ func generateExecID(command string, timestamp time.Time) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-func executeCommand(client *ssh.Client, command string, failIfNonzero bool) (string, int64, error) {
-	session, err := client.NewSession()
+// stderrTailLines is the number of trailing lines of stderr, or of the output, shown when a
+// command fails
+const stderrTailLines = 20
+
+// execResult holds what a command wrote and how it exited
+type execResult struct {
+	// Output interleaves stdout and stderr in the order they were received
+	Output   string
+	Stdout   string
+	Stderr   string
+	ExitCode int64
+}
+
+// combinedWriter collects the writes to stdout and stderr, which happen concurrently
+type combinedWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *combinedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+// execOptions controls how a command is run
+type execOptions struct {
+	FailIfNonzero  bool
+	RemoteForwards []RemoteForwardConfig
+	// Stdin is written to the standard input of the command, which is closed afterwards
+	Stdin       []byte
+	Environment map[string]string
+	WorkingDir  string
+	// Timeout bounds the time the command may run, unless zero
+	Timeout time.Duration
+	// TerminationSignals are sent in turn to a command that timed out or was canceled,
+	// waiting for the grace period after each of them
+	TerminationSignals     []ssh.Signal
+	TerminationGracePeriod time.Duration
+	// Become escalates the privileges of the command, unless nil
+	Become *becomeOptions
+}
+
+// Defaults of the termination of commands that timed out or were canceled
+var (
+	defaultTerminationSignals     = []string{"TERM", "KILL"}
+	defaultTerminationGracePeriod = 10 * time.Second
+)
+
+// terminationOptions parses the timeout and termination settings of a model, which are
+// validated by the schema
+func terminationOptions(options *execOptions, timeout types.String, signals types.List, gracePeriod types.String) {
+	options.Timeout, _ = time.ParseDuration(timeout.ValueString())
+
+	names := defaultTerminationSignals
+	if !signals.IsNull() {
+		names = listValueStrings(signals)
+	}
+	for _, name := range names {
+		options.TerminationSignals = append(options.TerminationSignals, ssh.Signal(name))
+	}
+
+	options.TerminationGracePeriod = defaultTerminationGracePeriod
+	if !gracePeriod.IsNull() {
+		options.TerminationGracePeriod, _ = time.ParseDuration(gracePeriod.ValueString())
+	}
+}
+
+// execInterruptedError reports a command that was terminated before it completed
+type execInterruptedError struct {
+	// Timeout is the timeout the command exceeded, or zero when it was canceled
+	Timeout time.Duration
+	// Signals are the signals sent before the command exited or its session was closed
+	Signals []ssh.Signal
+	// Closed reports whether the session was closed without the command exiting
+	Closed bool
+	Result execResult
+}
+
+func (e *execInterruptedError) Error() string {
+	signals := make([]string, 0, len(e.Signals))
+	for _, signal := range e.Signals {
+		signals = append(signals, "SIG"+string(signal))
+	}
+
+	var termination string
+	switch {
+	case !e.Closed:
+		termination = "exited after " + strings.Join(signals, ", ")
+	case len(signals) > 0:
+		termination = "did not exit after " + strings.Join(signals, ", ") + ", so its session was closed. It may still be running on the host"
+	default:
+		termination = "its session was closed. It may still be running on the host"
+	}
+
+	output := "The command produced no output."
+	if e.Result.Output != "" {
+		output = tailLines("Partial output", e.Result.Output)
+	}
+	return fmt.Sprintf("command %s and %s\n%s", e.reason(), termination, output)
+}
+
+func (e *execInterruptedError) reason() string {
+	if e.Timeout > 0 {
+		return fmt.Sprintf("timed out after %s", e.Timeout)
+	}
+	return "was canceled"
+}
+
+// execErrorSummary returns the summary of the diagnostic reporting a failed command
+func execErrorSummary(err error, summary string) string {
+	var interrupted *execInterruptedError
+	if errors.As(err, &interrupted) {
+		if interrupted.Timeout > 0 {
+			return "Command timed out"
+		}
+		return "Command canceled"
+	}
+	return summary
+}
+
+// commandEnvironment merges the environment variables of a command with the sensitive ones
+func commandEnvironment(environment types.Map, sensitiveEnvironment types.Map) map[string]string {
+	variables := mapValueStrings(environment)
+	for name, value := range mapValueStrings(sensitiveEnvironment) {
+		variables[name] = value
+	}
+	return variables
+}
+
+// validateEnvironment reports variables set both in environment and sensitive_environment
+func validateEnvironment(environment types.Map, sensitiveEnvironment types.Map) diag.Diagnostics {
+	var diags diag.Diagnostics
+	var names []string
+	for name := range sensitiveEnvironment.Elements() {
+		if _, ok := environment.Elements()[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		diags.AddAttributeError(
+			path.Root("sensitive_environment").AtMapKey(name),
+			"Conflicting environment variable",
+			fmt.Sprintf("%s is set in both environment and sensitive_environment. Set it in only one of them.", name),
+		)
+	}
+	return diags
+}
+
+// shellQuote quotes a value for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// prepareCommand sets the environment of the session and returns the command to run,
+// preceded by the export of the variables rejected by the server, or of all variables when
+// privileges are escalated, and the change of the working directory
+func prepareCommand(ctx context.Context, session *ssh.Session, command string, options execOptions) string {
+	names := make([]string, 0, len(options.Environment))
+	for name := range options.Environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var prefix strings.Builder
+	for _, name := range names {
+		value := options.Environment[name]
+		// Privilege escalation resets the environment, so the variables are always exported
+		if options.Become == nil {
+			if err := session.Setenv(name, value); err == nil {
+				continue
+			}
+			tflog.Debug(ctx, fmt.Sprintf("The server did not accept environment variable %s, exporting it in the command instead", name))
+		}
+		fmt.Fprintf(&prefix, "export %s=%s\n", name, shellQuote(value))
+	}
+	if options.WorkingDir != "" {
+		fmt.Fprintf(&prefix, "cd %s || exit $?\n", shellQuote(options.WorkingDir))
+	}
+	return prefix.String() + command
+}
+
+// stdinBytes returns the data to write to the standard input of a command, or nil when
+// neither stdin nor stdin_base64 is set
+func stdinBytes(stdin types.String, stdinBase64 types.String) ([]byte, error) {
+	if !stdinBase64.IsNull() {
+		data, err := base64.StdEncoding.DecodeString(stdinBase64.ValueString())
+		if err != nil {
+			return nil, fmt.Errorf("stdin_base64 is not valid base64: %w", err)
+		}
+		return data, nil
+	}
+	if !stdin.IsNull() {
+		return []byte(stdin.ValueString()), nil
+	}
+	return nil, nil
+}
+
+// stdinSHA256 returns the hash recorded in state in place of the standard input of a command
+func stdinSHA256(stdin []byte) types.String {
+	if stdin == nil {
+		return types.StringNull()
+	}
+	sum := sha256.Sum256(stdin)
+	return types.StringValue(hex.EncodeToString(sum[:]))
+}
+
+// executeCommand runs the command in a new session. The remote forwards are opened
+// before the command starts and closed once it completes.
+func executeCommand(ctx context.Context, manager *SSHManager, client *ssh.Client, command string, options execOptions) (execResult, error) {
+	result := execResult{ExitCode: -1}
+
+	closeForwards, client, err := withReconnect(ctx, manager, client, func(client *ssh.Client) (func(), error) {
+		return manager.OpenRemoteForwards(ctx, client, options.RemoteForwards)
+	})
+	if err != nil {
+		return result, err
+	}
+	defer closeForwards()
+
+	session, closeSession, err := manager.NewSession(ctx, client)
 	if err != nil {
-		return "", -1, fmt.Errorf("failed to create session: %w", err)
+		return result, err
 	}
-	defer session.Close()
+	defer closeSession()
 
-	outputBytes, err := session.CombinedOutput(command)
-	outputStr := string(outputBytes)
+	var stdout, stderr bytes.Buffer
+	var combined combinedWriter
+	session.Stdout = io.MultiWriter(&stdout, &combined)
+	session.Stderr = io.MultiWriter(&stderr, &combined)
+	command = prepareCommand(ctx, session, command, options)
+
+	// Escalating privileges holds back the standard input until the command runs
+	var become *becomeSession
+	if options.Become != nil {
+		become, command, err = startBecome(session, command, options)
+		if err != nil {
+			return result, err
+		}
+	} else if options.Stdin != nil {
+		session.Stdin = bytes.NewReader(options.Stdin)
+	}
+
+	if err := session.Start(command); err != nil {
+		return result, fmt.Errorf("failed to execute command: %w", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+	if become != nil {
+		go become.writeStdin(options.Stdin)
+	}
+
+	var timeout <-chan time.Time
+	if options.Timeout > 0 {
+		timer := time.NewTimer(options.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var interrupted *execInterruptedError
+	select {
+	case err = <-done:
+	case <-timeout:
+		interrupted = &execInterruptedError{Timeout: options.Timeout}
+	case <-ctx.Done():
+		interrupted = &execInterruptedError{}
+	}
+	if interrupted != nil {
+		tflog.Warn(ctx, fmt.Sprintf("Command %s, terminating it", interrupted.reason()))
+		interrupted.Signals, interrupted.Closed = terminateCommand(ctx, session, done, options)
+	}
+	if become != nil {
+		become.finish()
+	}
+
+	// The output is complete once the session is done
+	result.Output = combined.buf.String()
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+
+	if interrupted != nil {
+		interrupted.Result = result
+		return result, interrupted
+	}
+	if become != nil {
+		if err := become.err(); err != nil {
+			return result, err
+		}
+	}
 
 	if err != nil {
 		if exitErr, ok := err.(*ssh.ExitError); ok {
-			exitCode := int64(exitErr.ExitStatus())
-			if failIfNonzero && exitCode != 0 {
-				return outputStr, exitCode, fmt.Errorf("command exited with non-zero status: %d\nOutput: %s",
-					exitCode, outputStr)
+			result.ExitCode = int64(exitErr.ExitStatus())
+			if options.FailIfNonzero && result.ExitCode != 0 {
+				return result, fmt.Errorf("command exited with non-zero status: %d\n%s", result.ExitCode, describeFailure(result))
 			}
-			return outputStr, exitCode, nil
+			return result, nil
+		}
+		return result, fmt.Errorf("failed to execute command: %w", err)
+	}
+
+	result.ExitCode = 0
+	return result, nil
+}
+
+// terminateCommand sends the termination signals to the command in turn, waiting for the
+// grace period after each of them, and closes the session if it is still running. It
+// waits for the session to be done, and returns the signals sent and whether the session
+// had to be closed.
+func terminateCommand(ctx context.Context, session *ssh.Session, done <-chan error, options execOptions) ([]ssh.Signal, bool) {
+	var sent []ssh.Signal
+	for _, signal := range options.TerminationSignals {
+		if err := session.Signal(signal); err != nil {
+			tflog.Debug(ctx, fmt.Sprintf("Failed to send SIG%s to the command: %s", signal, err))
+			break
+		}
+		sent = append(sent, signal)
+
+		grace := time.NewTimer(options.TerminationGracePeriod)
+		select {
+		case <-done:
+			grace.Stop()
+			return sent, false
+		case <-grace.C:
 		}
-		return outputStr, -1, fmt.Errorf("failed to execute command: %w", err)
 	}
 
-	return outputStr, 0, nil
+	// Closing the channel also stops the output from being copied
+	session.Close()
+	<-done
+	return sent, true
+}
+
+// describeFailure shows the tail of the stderr of a failed command, or of its output when
+// it wrote nothing to stderr
+func describeFailure(result execResult) string {
+	if strings.TrimSpace(result.Stderr) == "" {
+		return "Output: " + result.Output
+	}
+	return tailLines("Stderr", result.Stderr)
+}
+
+// tailLines shows the last lines of the text, under the given label
+func tailLines(label string, text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) <= stderrTailLines {
+		return label + ": " + strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("%s (last %d of %d lines):\n%s", label, stderrTailLines, len(lines), strings.Join(lines[len(lines)-stderrTailLines:], "\n"))
 }
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestCommandEnvironment(t *testing.T) {
+	stringMap := func(values map[string]string) types.Map {
+		if values == nil {
+			return types.MapNull(types.StringType)
+		}
+		elements := make(map[string]attr.Value, len(values))
+		for key, value := range values {
+			elements[key] = types.StringValue(value)
+		}
+		return types.MapValueMust(types.StringType, elements)
+	}
+
+	tests := []struct {
+		name      string
+		plain     map[string]string
+		sensitive map[string]string
+		want      map[string]string
+		wantErr   string
+	}{
+		{name: "none", want: map[string]string{}},
+		{name: "plain only", plain: map[string]string{"CHANNEL": "stable"}, want: map[string]string{"CHANNEL": "stable"}},
+		{name: "sensitive only", sensitive: map[string]string{"TOKEN": "s3cr3t"}, want: map[string]string{"TOKEN": "s3cr3t"}},
+		{
+			name:      "merged",
+			plain:     map[string]string{"CHANNEL": "stable"},
+			sensitive: map[string]string{"TOKEN": "s3cr3t"},
+			want:      map[string]string{"CHANNEL": "stable", "TOKEN": "s3cr3t"},
+		},
+		{
+			name:      "set in both",
+			plain:     map[string]string{"TOKEN": "plain"},
+			sensitive: map[string]string{"TOKEN": "s3cr3t"},
+			wantErr:   "TOKEN is set in both environment and sensitive_environment",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain, sensitive := stringMap(tt.plain), stringMap(tt.sensitive)
+
+			diags := validateEnvironment(plain, sensitive)
+			if tt.wantErr != "" {
+				if diags.ErrorsCount() != 1 || !strings.Contains(diags.Errors()[0].Detail(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, diags)
+				}
+				return
+			}
+			if diags.HasError() {
+				t.Fatalf("unexpected error: %v", diags)
+			}
+			if got := commandEnvironment(plain, sensitive); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
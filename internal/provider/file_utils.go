@@ -11,7 +11,6 @@ import (
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
-	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -32,13 +31,21 @@ func generateFileID(path string, timestamp time.Time) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-// readFile reads a file's contents over SFTP
-func readFile(client *ssh.Client, path string) (string, error) {
-	sftpClient, err := sftp.NewClient(client)
+// readFile reads a file's contents over SFTP, or with a command when privileges are escalated
+func readFile(ctx context.Context, manager *SSHManager, client *ssh.Client, path string, become *becomeOptions) (string, error) {
+	if become != nil {
+		result, err := executeCommand(ctx, manager, client, "cat -- "+shellQuote(path), execOptions{FailIfNonzero: true, Become: become})
+		if err != nil {
+			return "", fmt.Errorf("failed to read file as %s: %w", become.User, err)
+		}
+		return result.Stdout, nil
+	}
+
+	sftpClient, closeSFTP, err := manager.NewSFTPClient(ctx, client)
 	if err != nil {
-		return "", fmt.Errorf("failed to create SFTP client: %w", err)
+		return "", err
 	}
-	defer sftpClient.Close()
+	defer closeSFTP()
 
 	f, err := sftpClient.Open(path)
 	if err != nil {
@@ -55,13 +62,22 @@ func readFile(client *ssh.Client, path string) (string, error) {
 	return string(content), nil
 }
 
-// writeFile writes content to a file over SFTP
-func writeFile(ctx context.Context, client *ssh.Client, path, content string, permissions string) error {
-	sftpClient, err := sftp.NewClient(client)
+// writeFile writes content to a file over SFTP, or with a command when privileges are
+// escalated
+func writeFile(ctx context.Context, manager *SSHManager, client *ssh.Client, path, content string, permissions string, become *becomeOptions) error {
+	if become != nil {
+		command := privilegedWriteCommand(path, parseFileMode(permissions))
+		if _, err := executeCommand(ctx, manager, client, command, execOptions{FailIfNonzero: true, Stdin: []byte(content), Become: become}); err != nil {
+			return fmt.Errorf("failed to write file as %s: %w", become.User, err)
+		}
+		return nil
+	}
+
+	sftpClient, closeSFTP, err := manager.NewSFTPClient(ctx, client)
 	if err != nil {
-		return fmt.Errorf("failed to create SFTP client: %w", err)
+		return err
 	}
-	defer sftpClient.Close()
+	defer closeSFTP()
 
 	// Create directory if needed
 	dirPath := filepath.Dir(path)
@@ -97,13 +113,20 @@ func writeFile(ctx context.Context, client *ssh.Client, path, content string, pe
 	return nil
 }
 
-// deleteFile deletes a file over SFTP
-func deleteFile(client *ssh.Client, path string) error {
-	sftpClient, err := sftp.NewClient(client)
+// deleteFile deletes a file over SFTP, or with a command when privileges are escalated
+func deleteFile(ctx context.Context, manager *SSHManager, client *ssh.Client, path string, become *becomeOptions) error {
+	if become != nil {
+		if _, err := executeCommand(ctx, manager, client, "rm -- "+shellQuote(path), execOptions{FailIfNonzero: true, Become: become}); err != nil {
+			return fmt.Errorf("failed to delete file as %s: %w", become.User, err)
+		}
+		return nil
+	}
+
+	sftpClient, closeSFTP, err := manager.NewSFTPClient(ctx, client)
 	if err != nil {
-		return fmt.Errorf("failed to create SFTP client: %w", err)
+		return err
 	}
-	defer sftpClient.Close()
+	defer closeSFTP()
 
 	if err := sftpClient.Remove(path); err != nil {
 		return fmt.Errorf("failed to delete file: %w", err)
@@ -111,3 +134,16 @@ func deleteFile(client *ssh.Client, path string) error {
 
 	return nil
 }
+
+// privilegedWriteCommand returns the command writing its standard input to the file. The
+// content is staged in a temporary file next to it, which is moved in place once complete
+// so that the file is replaced atomically.
+func privilegedWriteCommand(path string, mode fs.FileMode) string {
+	return fmt.Sprintf(`dir=%s
+mkdir -p -- "$dir" || exit
+staging=$(mktemp "$dir/.terraform-ssh.XXXXXX") || exit
+cat > "$staging" && chmod %o "$staging" && mv -f -- "$staging" %s
+status=$?
+[ $status -eq 0 ] || rm -f -- "$staging"
+exit $status`, shellQuote(filepath.Dir(path)), mode, shellQuote(path))
+}
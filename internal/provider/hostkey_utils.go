@@ -0,0 +1,247 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Host key checking policies
+const (
+	hostKeyPolicyStrict    = "strict"
+	hostKeyPolicyAcceptNew = "accept-new"
+	hostKeyPolicyOff       = "off"
+)
+
+// defaultKnownHostsFile is used when no known_hosts files are configured
+const defaultKnownHostsFile = "~/.ssh/known_hosts"
+
+// knownHostsLock serializes writes to known_hosts files and to sessionHostKeys
+var knownHostsLock sync.Mutex
+
+// sessionHostKeys holds the keys of hosts trusted on first use while no known_hosts files
+// are configured, keyed by normalized address. They are only kept while the provider runs,
+// so that the operator's own known_hosts file is never written implicitly.
+var sessionHostKeys = map[string]ssh.PublicKey{}
+
+// newHostKeyCallback builds the host key callback for a connection from its
+// known_hosts files, pinned host keys and host key checking policy. It also returns
+// the host key algorithms matching the keys already known for the address, so that
+// the server is asked for a key type that can actually be verified.
+func newHostKeyCallback(config SSHConnectionConfig, address string) (ssh.HostKeyCallback, []string, error) {
+	policy := hostKeyPolicyAcceptNew
+	if len(config.HostKeys) > 0 {
+		policy = hostKeyPolicyStrict
+	}
+	if config.StrictHostKeyChecking != nil {
+		policy = *config.StrictHostKeyChecking
+	}
+
+	switch policy {
+	case hostKeyPolicyOff:
+		return ssh.InsecureIgnoreHostKey(), nil, nil
+	case hostKeyPolicyStrict, hostKeyPolicyAcceptNew:
+	default:
+		return nil, nil, fmt.Errorf("unsupported host key checking policy %q", policy)
+	}
+
+	// Pinned host keys take precedence over known_hosts files
+	if len(config.HostKeys) > 0 {
+		return newPinnedHostKeyCallback(config.HostKeys)
+	}
+
+	configured := config.KnownHostsFiles
+	if len(configured) == 0 {
+		configured = []string{defaultKnownHostsFile}
+	}
+	files := make([]string, len(configured))
+	for i, file := range configured {
+		files[i] = expandPath(file)
+	}
+
+	// Only load files that exist, missing files are treated as empty
+	var existing []string
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+
+	var known ssh.HostKeyCallback
+	if len(existing) > 0 {
+		callback, err := knownhosts.New(existing...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to load known_hosts files: %w", err)
+		}
+		known = callback
+	}
+
+	// Probe the database with a key that never matches to learn which keys are known
+	var algorithms []string
+	if known != nil {
+		var keyErr *knownhosts.KeyError
+		if err := known(address, &net.TCPAddr{}, probeHostKey); errors.As(err, &keyErr) {
+			for _, want := range keyErr.Want {
+				algorithms = append(algorithms, hostKeyAlgorithms(want.Key.Type())...)
+			}
+		}
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		var err error = &knownhosts.KeyError{}
+		if known != nil {
+			err = known(hostname, remote, key)
+		}
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		var revokedErr *knownhosts.RevokedError
+		switch {
+		case errors.As(err, &revokedErr):
+			return fmt.Errorf("host key verification failed for %s: presented %s key %s is revoked in %s",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), revokedErr.Revoked.Filename)
+		case errors.As(err, &keyErr) && len(keyErr.Want) > 0:
+			var expected []string
+			for _, want := range keyErr.Want {
+				expected = append(expected, fmt.Sprintf("%s %s (%s:%d)",
+					want.Key.Type(), ssh.FingerprintSHA256(want.Key), want.Filename, want.Line))
+			}
+			return fmt.Errorf("host key verification failed for %s: presented %s key %s does not match known key(s): %s",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), strings.Join(expected, ", "))
+		case errors.As(err, &keyErr):
+			if policy == hostKeyPolicyAcceptNew {
+				// Only known_hosts files configured explicitly are written to
+				if len(config.KnownHostsFiles) == 0 {
+					return acceptSessionHostKey(hostname, key)
+				}
+				if err := appendKnownHost(files[0], hostname, remote, key); err != nil {
+					return fmt.Errorf("unable to record host key for %s: %w", hostname, err)
+				}
+				return nil
+			}
+			return fmt.Errorf("host key verification failed for %s: host is not present in %s, presented %s key %s",
+				hostname, strings.Join(files, ", "), key.Type(), ssh.FingerprintSHA256(key))
+		default:
+			return fmt.Errorf("host key verification failed for %s: %w", hostname, err)
+		}
+	}, algorithms, nil
+}
+
+// newPinnedHostKeyCallback builds a host key callback accepting only the given public
+// keys (authorized_keys format) or SHA256 fingerprints
+func newPinnedHostKeyCallback(hostKeys []string) (ssh.HostKeyCallback, []string, error) {
+	fingerprints := make(map[string]bool, len(hostKeys))
+	var algorithms []string
+	onlyKeys := true
+	for _, hostKey := range hostKeys {
+		hostKey = strings.TrimSpace(hostKey)
+		if strings.HasPrefix(hostKey, "SHA256:") {
+			fingerprints[hostKey] = true
+			onlyKeys = false
+			continue
+		}
+
+		// Accept both plain public keys and known_hosts lines
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+		if err != nil {
+			var knownErr error
+			if _, _, key, _, _, knownErr = ssh.ParseKnownHosts([]byte(hostKey)); knownErr != nil {
+				return nil, nil, fmt.Errorf("unable to parse host key %q: %w", hostKey, err)
+			}
+		}
+		fingerprints[ssh.FingerprintSHA256(key)] = true
+		algorithms = append(algorithms, hostKeyAlgorithms(key.Type())...)
+	}
+
+	// Fingerprints do not reveal the key type, so any algorithm may be negotiated
+	if !onlyKeys {
+		algorithms = nil
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		if fingerprints[fingerprint] {
+			return nil
+		}
+		return fmt.Errorf("host key verification failed for %s: presented %s key %s does not match any pinned host key",
+			hostname, key.Type(), fingerprint)
+	}, algorithms, nil
+}
+
+// probeHostKey is a fixed key used to look up the known keys of a host
+var probeHostKey = func() ssh.PublicKey {
+	key, err := ssh.NewPublicKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public())
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// hostKeyAlgorithms returns the host key algorithms able to produce a key of the given type
+func hostKeyAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+// acceptSessionHostKey trusts the key of a host missing from the known_hosts files for the
+// lifetime of the provider, rejecting a different key presented later on
+func acceptSessionHostKey(hostname string, key ssh.PublicKey) error {
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+
+	address := knownhosts.Normalize(hostname)
+	if accepted, ok := sessionHostKeys[address]; ok && !bytes.Equal(accepted.Marshal(), key.Marshal()) {
+		return fmt.Errorf("host key verification failed for %s: presented %s key %s does not match the %s key %s accepted earlier",
+			hostname, key.Type(), ssh.FingerprintSHA256(key), accepted.Type(), ssh.FingerprintSHA256(accepted))
+	}
+	sessionHostKeys[address] = key
+	return nil
+}
+
+// appendKnownHost records a previously unknown host key in the given known_hosts file
+func appendKnownHost(file string, hostname string, remote net.Addr, key ssh.PublicKey) error {
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", file, err)
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	defer f.Close()
+
+	addresses := []string{knownhosts.Normalize(hostname)}
+	if remote != nil {
+		if remoteAddr := knownhosts.Normalize(remote.String()); remoteAddr != addresses[0] && !isUnspecifiedAddr(remote) {
+			addresses = append(addresses, remoteAddr)
+		}
+	}
+
+	if _, err := fmt.Fprintln(f, knownhosts.Line(addresses, key)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return nil
+}
+
+// isUnspecifiedAddr reports whether the address carries no usable IP, which is the case
+// for connections tunneled through a bastion
+func isUnspecifiedAddr(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return !ok || tcpAddr.IP == nil || tcpAddr.IP.IsUnspecified()
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func testHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestNewHostKeyCallback_AcceptNew(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}
+
+	t.Run("default known_hosts is not written", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+
+		callback, _, err := newHostKeyCallback(SSHConnectionConfig{}, "first-use.example.com:22")
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := testHostKey(t)
+		if err := callback("first-use.example.com:22", remote, key); err != nil {
+			t.Fatalf("unknown host was not trusted on first use: %s", err)
+		}
+		if _, err := os.Stat(filepath.Join(home, ".ssh", "known_hosts")); !os.IsNotExist(err) {
+			t.Fatalf("~/.ssh/known_hosts was written: %v", err)
+		}
+
+		// The key accepted on first use is remembered while the provider runs
+		if err := callback("first-use.example.com:22", remote, key); err != nil {
+			t.Fatalf("accepted key was rejected: %s", err)
+		}
+		err = callback("first-use.example.com:22", remote, testHostKey(t))
+		if err == nil || !strings.Contains(err.Error(), "accepted earlier") {
+			t.Fatalf("changed key was not rejected: %v", err)
+		}
+	})
+
+	t.Run("configured known_hosts is written", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "known_hosts")
+		config := SSHConnectionConfig{KnownHostsFiles: []string{file}}
+
+		callback, _, err := newHostKeyCallback(config, "recorded.example.com:22")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := callback("recorded.example.com:22", remote, testHostKey(t)); err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(content), "recorded.example.com,192.0.2.1 ssh-ed25519 ") {
+			t.Fatalf("unexpected known_hosts content %q", content)
+		}
+
+		// A new callback loads the recorded key and rejects a different one
+		callback, _, err = newHostKeyCallback(config, "recorded.example.com:22")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = callback("recorded.example.com:22", remote, testHostKey(t))
+		if err == nil || !strings.Contains(err.Error(), "does not match known key") {
+			t.Fatalf("changed key was not rejected: %v", err)
+		}
+	})
+
+	t.Run("strict rejects unknown hosts", func(t *testing.T) {
+		strict := hostKeyPolicyStrict
+		config := SSHConnectionConfig{KnownHostsFiles: []string{filepath.Join(t.TempDir(), "known_hosts")}, StrictHostKeyChecking: &strict}
+
+		callback, _, err := newHostKeyCallback(config, "unknown.example.com:22")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = callback("unknown.example.com:22", remote, testHostKey(t))
+		if err == nil || !strings.Contains(err.Error(), "is not present in") {
+			t.Fatalf("unknown host was not rejected: %v", err)
+		}
+	})
+}
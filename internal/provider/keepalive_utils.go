@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Defaults of the keepalive and liveness checks
+const (
+	defaultKeepaliveCountMax = 3
+	defaultLivenessTimeout   = 15 * time.Second
+)
+
+// keepalivePolicy holds the keepalive settings of a connection
+type keepalivePolicy struct {
+	interval time.Duration
+	countMax int64
+}
+
+// newKeepalivePolicy parses the keepalive settings of a connection
+func newKeepalivePolicy(config SSHConnectionConfig) (keepalivePolicy, error) {
+	policy := keepalivePolicy{countMax: defaultKeepaliveCountMax}
+	if config.KeepaliveInterval != nil {
+		interval, err := time.ParseDuration(*config.KeepaliveInterval)
+		if err != nil {
+			return policy, fmt.Errorf("invalid keepalive_interval %q: %w", *config.KeepaliveInterval, err)
+		}
+		policy.interval = interval
+	}
+	if config.KeepaliveCountMax != nil {
+		policy.countMax = *config.KeepaliveCountMax
+	}
+	return policy, nil
+}
+
+// start sends keepalive requests over the client in the background until it is closed.
+// The client is closed once countMax consecutive requests go unanswered, so that it is
+// detected as dead and re-dialed on its next use.
+func (p keepalivePolicy) start(client *ssh.Client) {
+	if p.interval <= 0 {
+		return
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(closed)
+	}()
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		var missed int64
+		for {
+			select {
+			case <-closed:
+				return
+			case <-ticker.C:
+			}
+
+			if clientAlive(client, p.interval) {
+				missed = 0
+				continue
+			}
+			missed++
+			if missed >= p.countMax {
+				client.Close()
+				return
+			}
+		}
+	}()
+}
+
+// clientAlive reports whether the server answers a keepalive request within the timeout.
+// Servers reply to unknown global requests with a failure, which still proves liveness.
+func clientAlive(client *ssh.Client, timeout time.Duration) bool {
+	_, err := withTimeout(timeout, func() (bool, error) {
+		ok, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		return ok, err
+	}, func(bool) {})
+	return err == nil
+}
+
+// dialFunc gets the client of a connection from the manager
+type dialFunc func(ctx context.Context) (*ssh.Client, error)
+
+// redial gets a client returned by GetClient again, with the arguments it was requested with
+type redial struct {
+	key  connectionKey
+	dial dialFunc
+}
+
+// rememberDial records how to get the client again once its connection has closed. The
+// record outlives the connection, since operations may still hold the client when it
+// closes, and is only dropped when the manager evicts an idle client or is closed.
+func (m *SSHManager) rememberDial(key connectionKey, client *ssh.Client, dial dialFunc) {
+	m.cacheLock.Lock()
+	defer m.cacheLock.Unlock()
+	m.redials[client] = redial{key: key, dial: dial}
+}
+
+// reconnect evicts a client whose connection has closed and gets it again from GetClient,
+// which re-dials the bastions and jump hosts it is tunneled through when their connections
+// have closed as well. It fails when the client was not returned by GetClient.
+func (m *SSHManager) reconnect(ctx context.Context, client *ssh.Client, cause error) (*ssh.Client, error) {
+	m.cacheLock.Lock()
+	r, ok := m.redials[client]
+	m.uncache(client)
+	m.cacheLock.Unlock()
+	client.Close()
+
+	if !ok {
+		return nil, cause
+	}
+	tflog.Info(ctx, fmt.Sprintf("SSH connection closed while in use, reconnecting: %s", cause))
+	reconnected, err := r.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w (reconnecting failed: %w)", cause, err)
+	}
+	return reconnected, nil
+}
+
+// withReconnect runs open on the client. When it fails because the connection has closed,
+// the client is reconnected and open is retried once.
+func withReconnect[T any](ctx context.Context, m *SSHManager, client *ssh.Client, open func(*ssh.Client) (T, error)) (T, *ssh.Client, error) {
+	value, err := open(client)
+	if err == nil || !isConnectionClosed(client, err) {
+		return value, client, err
+	}
+
+	client, err = m.reconnect(ctx, client, err)
+	if err != nil {
+		var zero T
+		return zero, nil, err
+	}
+	value, err = open(client)
+	return value, client, err
+}
+
+// isConnectionClosed reports whether an operation on the client failed because its
+// connection has closed, locally or by the server. Connections closing while a channel is
+// being opened fail with other errors, so the server is asked whether it is still there.
+func isConnectionClosed(client *ssh.Client, err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	return !clientAlive(client, defaultLivenessTimeout)
+}
@@ -0,0 +1,258 @@
+package provider
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer is an in-process SSH server accepting the password "secret". It accepts
+// sessions without running anything in them, and direct-tcpip channels to itself, so that
+// it can serve as its own bastion.
+type testSSHServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+
+	mu    sync.Mutex
+	conns []*ssh.ServerConn
+	dials int
+}
+
+func newTestSSHServer(t *testing.T) *testSSHServer {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "secret" {
+				return nil, errors.New("wrong password")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSSHServer{listener: listener, config: config}
+	t.Cleanup(func() {
+		listener.Close()
+		s.closeConnections()
+	})
+	go s.serve()
+	return s
+}
+
+func (s *testSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testSSHServer) handle(conn net.Conn) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, serverConn)
+	s.dials++
+	s.mu.Unlock()
+
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		switch newChannel.ChannelType() {
+		case "session":
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go func() {
+				for request := range requests {
+					request.Reply(false, nil)
+				}
+				channel.Close()
+			}()
+		case "direct-tcpip":
+			target, err := net.Dial("tcp", s.listener.Addr().String())
+			if err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				target.Close()
+				continue
+			}
+			go ssh.DiscardRequests(requests)
+			go func() {
+				io.Copy(target, channel)
+				target.Close()
+			}()
+			go func() {
+				io.Copy(channel, target)
+				channel.Close()
+			}()
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+// closeConnections drops every connection to the server, as a NAT or firewall timeout would
+func (s *testSSHServer) closeConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testSSHServer) dialCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials
+}
+
+// connectionConfig returns the config connecting to the server
+func (s *testSSHServer) connectionConfig() SSHConnectionConfig {
+	host, portString, _ := net.SplitHostPort(s.listener.Addr().String())
+	port, _ := strconv.ParseInt(portString, 10, 64)
+	user, password, policy := "test", "secret", hostKeyPolicyOff
+	return SSHConnectionConfig{Host: &host, Port: &port, User: &user, Password: &password, StrictHostKeyChecking: &policy}
+}
+
+func TestNewSession_Reconnect(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		jumpHosts int
+		// dials is the number of connections expected after reconnecting, including the
+		// jump hosts
+		dials int
+	}{
+		{name: "direct", jumpHosts: 0, dials: 2},
+		{name: "through jump host", jumpHosts: 1, dials: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestSSHServer(t)
+			manager, err := NewSSHManager(&SSHConnectionConfig{}, nil, SSHManagerOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer manager.Close()
+
+			var jumpHosts []SSHConnectionConfig
+			for i := 0; i < tt.jumpHosts; i++ {
+				jumpHosts = append(jumpHosts, server.connectionConfig())
+			}
+			client, err := manager.GetClient(ctx, server.connectionConfig(), false, jumpHosts, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The connection dies after the client was handed out, and is re-dialed with
+			// its jump hosts when the session is opened
+			server.closeConnections()
+			session, closeSession, err := manager.NewSession(ctx, client)
+			if err != nil {
+				t.Fatalf("session was not opened on the re-dialed client: %s", err)
+			}
+			session.Close()
+			closeSession()
+			if dials := server.dialCount(); dials != tt.dials {
+				t.Errorf("expected %d connections, got %d", tt.dials, dials)
+			}
+
+			reconnected, err := manager.GetClient(ctx, server.connectionConfig(), false, jumpHosts, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reconnected == client {
+				t.Error("the closed client is still cached")
+			}
+		})
+	}
+
+	t.Run("reconnecting fails", func(t *testing.T) {
+		server := newTestSSHServer(t)
+		manager, err := NewSSHManager(&SSHConnectionConfig{}, nil, SSHManagerOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer manager.Close()
+
+		client, err := manager.GetClient(ctx, server.connectionConfig(), false, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.listener.Close()
+		server.closeConnections()
+		if _, _, err := manager.NewSFTPClient(ctx, client); err == nil {
+			t.Fatal("expected an error once the server is gone")
+		}
+	})
+}
+
+func TestIsConnectionClosed(t *testing.T) {
+	server := newTestSSHServer(t)
+	config := server.connectionConfig()
+	client, err := ssh.Dial("tcp", net.JoinHostPort(*config.Host, strconv.FormatInt(*config.Port, 10)), &ssh.ClientConfig{
+		User:            *config.User,
+		Auth:            []ssh.AuthMethod{ssh.Password(*config.Password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "net.ErrClosed", err: &net.OpError{Op: "read", Err: net.ErrClosed}, want: true},
+		{name: "other error of live connection", err: &ssh.OpenChannelError{Reason: ssh.Prohibited}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnectionClosed(client, tt.err); got != tt.want {
+				t.Errorf("isConnectionClosed(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("closed connection", func(t *testing.T) {
+		server.closeConnections()
+		client.Wait()
+		if !isConnectionClosed(client, &ssh.OpenChannelError{Reason: ssh.Prohibited}) {
+			t.Error("closed connection was not detected")
+		}
+	})
+}
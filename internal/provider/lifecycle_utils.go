@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// managedClient tracks the lifecycle of an SSH connection opened by the manager
+type managedClient struct {
+	client *ssh.Client
+	// parent is the connection this one is tunneled through, if any
+	parent *managedClient
+	// children counts the open connections tunneled through this one
+	children int
+	// sessions counts the open sessions and SFTP clients
+	sessions int
+	lastUsed time.Time
+}
+
+// depth returns the number of connections this one is tunneled through
+func (c *managedClient) depth() int {
+	depth := 0
+	for parent := c.parent; parent != nil; parent = parent.parent {
+		depth++
+	}
+	return depth
+}
+
+// trackClient registers a newly opened client, holding a reference on the client it is
+// tunneled through. Once the client is closed, the reference is released and the client
+// is removed from the cache.
+func (m *SSHManager) trackClient(client *ssh.Client, fromClient *ssh.Client) {
+	m.cacheLock.Lock()
+	entry := &managedClient{client: client, parent: m.clients[fromClient], lastUsed: time.Now()}
+	if entry.parent != nil {
+		entry.parent.children++
+	}
+	m.clients[client] = entry
+	m.cacheLock.Unlock()
+
+	go func() {
+		client.Wait()
+
+		m.cacheLock.Lock()
+		defer m.cacheLock.Unlock()
+		delete(m.clients, client)
+		if entry.parent != nil {
+			entry.parent.children--
+			entry.parent.lastUsed = time.Now()
+		}
+		m.uncache(client)
+	}()
+}
+
+// touchClient marks the client as used. The caller must hold cacheLock.
+func (m *SSHManager) touchClient(client *ssh.Client) {
+	if entry, ok := m.clients[client]; ok {
+		entry.lastUsed = time.Now()
+	}
+}
+
+// trackSession counts an open session on the client, keeping it from being evicted while
+// idle. The returned function must be called once the session is closed.
+func (m *SSHManager) trackSession(client *ssh.Client) func() {
+	m.cacheLock.Lock()
+	defer m.cacheLock.Unlock()
+	entry, ok := m.clients[client]
+	if !ok {
+		return func() {}
+	}
+	entry.sessions++
+	entry.lastUsed = time.Now()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.cacheLock.Lock()
+			defer m.cacheLock.Unlock()
+			entry.sessions--
+			entry.lastUsed = time.Now()
+		})
+	}
+}
+
+// evictIdleClients periodically closes clients that have had no open sessions and no
+// connections tunneled through them for the idle timeout. Bastions are released once
+// the last connection through them has been evicted.
+func (m *SSHManager) evictIdleClients(idleTimeout time.Duration) {
+	interval := min(max(idleTimeout/4, time.Second), time.Minute)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		var idle []*ssh.Client
+		m.cacheLock.Lock()
+		for client, entry := range m.clients {
+			if entry.children == 0 && entry.sessions == 0 && now.Sub(entry.lastUsed) >= idleTimeout {
+				idle = append(idle, client)
+				m.uncache(client)
+				delete(m.redials, client)
+			}
+		}
+		m.cacheLock.Unlock()
+
+		for _, client := range idle {
+			client.Close()
+		}
+	}
+}
+
+// uncache removes every cache entry pointing at the client. The caller must hold cacheLock.
+func (m *SSHManager) uncache(client *ssh.Client) {
+	for key, cached := range m.clientCache {
+		if cached == client {
+			delete(m.clientCache, key)
+		}
+	}
+}
+
+// Close closes all tunnels and connections of the manager, starting with the innermost
+// connections so that every bastion is closed after the connections tunneled through it.
+func (m *SSHManager) Close() {
+	m.closeOnce.Do(func() { close(m.done) })
+	m.closeTunnels()
+
+	m.cacheLock.Lock()
+	entries := make([]*managedClient, 0, len(m.clients))
+	for _, entry := range m.clients {
+		entries = append(entries, entry)
+	}
+	m.clientCache = make(map[connectionKey]*ssh.Client)
+	m.redials = make(map[*ssh.Client]redial)
+	m.cacheLock.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].depth() > entries[j].depth()
+	})
+	for _, entry := range entries {
+		entry.client.Close()
+	}
+}
+
+// managers holds every SSH manager created by the provider, so that their connections
+// can be closed when the provider process shuts down
+var managers struct {
+	sync.Mutex
+	list []*SSHManager
+}
+
+// registerManager adds the manager to the managers closed by Shutdown
+func registerManager(manager *SSHManager) {
+	managers.Lock()
+	defer managers.Unlock()
+	managers.list = append(managers.list, manager)
+}
+
+// unregisterManager removes the manager from the managers closed by Shutdown
+func unregisterManager(manager *SSHManager) {
+	managers.Lock()
+	defer managers.Unlock()
+	managers.list = slices.DeleteFunc(managers.list, func(registered *SSHManager) bool {
+		return registered == manager
+	})
+}
+
+// Shutdown closes the connections of every SSH manager. It is called when the provider
+// server has stopped.
+func Shutdown() {
+	managers.Lock()
+	list := managers.list
+	managers.list = nil
+	managers.Unlock()
+
+	for _, manager := range list {
+		manager.Close()
+	}
+}
@@ -0,0 +1,167 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// clientEntry returns the lifecycle entry of the client, or nil once it has been closed
+func clientEntry(manager *SSHManager, client *ssh.Client) *managedClient {
+	manager.cacheLock.Lock()
+	defer manager.cacheLock.Unlock()
+	return manager.clients[client]
+}
+
+// waitClosed waits for the client to be closed and forgotten by the manager
+func waitClosed(t *testing.T, manager *SSHManager, client *ssh.Client, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for clientEntry(manager, client) != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("client was not closed within %s", timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestManagedClient_References(t *testing.T) {
+	ctx := context.Background()
+	server := newTestSSHServer(t)
+	manager, err := NewSSHManager(&SSHConnectionConfig{}, nil, SSHManagerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	jumpHost, err := manager.GetClient(ctx, server.connectionConfig(), false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := manager.GetClient(ctx, server.connectionConfig(), false, []SSHConnectionConfig{server.connectionConfig()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var closeSession func()
+	tests := []struct {
+		name     string
+		action   func(t *testing.T)
+		children int
+		sessions int
+	}{
+		{name: "connection through jump host", action: func(t *testing.T) {}, children: 1},
+		{name: "session on jump host", action: func(t *testing.T) {
+			var err error
+			_, closeSession, err = manager.NewSession(ctx, jumpHost)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}, children: 1, sessions: 1},
+		{name: "connection through jump host closed", action: func(t *testing.T) {
+			client.Close()
+			waitClosed(t, manager, client, time.Second)
+		}, sessions: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.action(t)
+			entry := clientEntry(manager, jumpHost)
+			if entry == nil {
+				t.Fatal("jump host is not tracked")
+			}
+			manager.cacheLock.Lock()
+			children, sessions := entry.children, entry.sessions
+			manager.cacheLock.Unlock()
+			if children != tt.children || sessions != tt.sessions {
+				t.Errorf("expected %d children and %d sessions, got %d and %d", tt.children, tt.sessions, children, sessions)
+			}
+		})
+	}
+
+	if closeSession == nil {
+		t.FailNow()
+	}
+	closeSession()
+	if entry := clientEntry(manager, jumpHost); entry == nil || entry.sessions != 0 {
+		t.Error("closing the session did not release it")
+	}
+}
+
+func TestEvictIdleClients(t *testing.T) {
+	ctx := context.Background()
+	server := newTestSSHServer(t)
+	manager, err := NewSSHManager(&SSHConnectionConfig{}, nil, SSHManagerOptions{IdleTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	jumpHost, err := manager.GetClient(ctx, server.connectionConfig(), false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := manager.GetClient(ctx, server.connectionConfig(), false, []SSHConnectionConfig{server.connectionConfig()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A client with an open session is kept, and so is the jump host underneath it
+	_, closeSession, err := manager.NewSession(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if clientEntry(manager, client) == nil || clientEntry(manager, jumpHost) == nil {
+		t.Fatal("clients in use were evicted")
+	}
+
+	// Once idle, the client is evicted first and the jump host once nothing uses it anymore
+	closeSession()
+	waitClosed(t, manager, client, 3*time.Second)
+	waitClosed(t, manager, jumpHost, 3*time.Second)
+
+	reconnected, err := manager.GetClient(ctx, server.connectionConfig(), false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reconnected == jumpHost {
+		t.Error("evicted client is still cached")
+	}
+}
+
+func TestManagerClose(t *testing.T) {
+	ctx := context.Background()
+	server := newTestSSHServer(t)
+	manager, err := NewSSHManager(&SSHConnectionConfig{}, nil, SSHManagerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registerManager(manager)
+	client, err := manager.GetClient(ctx, server.connectionConfig(), false, []SSHConnectionConfig{server.connectionConfig()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unregisterManager(manager)
+	managers.Lock()
+	registered := slices.Contains(managers.list, manager)
+	managers.Unlock()
+	if registered {
+		t.Fatal("manager is still registered")
+	}
+
+	manager.Close()
+	waitClosed(t, manager, client, time.Second)
+	manager.cacheLock.Lock()
+	open, cached := len(manager.clients), len(manager.clientCache)
+	manager.cacheLock.Unlock()
+	if open != 0 || cached != 0 {
+		t.Errorf("expected every client to be closed, %d are open and %d cached", open, cached)
+	}
+}
@@ -1,14 +1,19 @@
 package provider
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/kevinburke/ssh_config"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -25,97 +30,244 @@ func hashSensitive(s string) string {
 }
 
 // newConnectionKey creates a connectionKey from connection parameters
-func newConnectionKey(config SSHConnectionConfig, useProviderAsBastion bool, bastion *SSHConnectionConfig, fromClient *ssh.Client) connectionKey {
+func newConnectionKey(config SSHConnectionConfig, useProviderAsBastion bool, jumpHosts []SSHConnectionConfig, fromClient *ssh.Client) connectionKey {
 	var parts []string
 
 	// Add main connection details
+	parts = append(parts, connectionKeyParts("", config)...)
+
+	// Add bastion flag
+	parts = append(parts, fmt.Sprintf("useProviderBastion=%v", useProviderAsBastion))
+
+	// Add jump host details if present
+	if len(jumpHosts) > 0 {
+		for i, hop := range jumpHosts {
+			parts = append(parts, connectionKeyParts(fmt.Sprintf("jump%d_", i), hop)...)
+		}
+	} else {
+		parts = append(parts, "jump_hosts=<nil>")
+	}
+
+	// Add fromClient address if present
+	if fromClient != nil {
+		parts = append(parts, fmt.Sprintf("from=%s", fromClient.RemoteAddr().String()))
+	} else {
+		parts = append(parts, "from=<nil>")
+	}
+
+	return connectionKey(strings.Join(parts, "|"))
+}
+
+// connectionKeyParts returns the identifying parts of a single connection, each name
+// prefixed with the given prefix
+func connectionKeyParts(prefix string, config SSHConnectionConfig) []string {
+	var parts []string
+
+	connectionVal := "<nil>"
+	if config.Connection != nil {
+		connectionVal = *config.Connection
+	}
+	parts = append(parts, fmt.Sprintf("%sconnection=%s", prefix, connectionVal))
+
 	hostVal := "<nil>"
 	if config.Host != nil {
 		hostVal = *config.Host
 	}
-	parts = append(parts, fmt.Sprintf("host=%s", hostVal))
+	parts = append(parts, fmt.Sprintf("%shost=%s", prefix, hostVal))
 
 	userVal := "<nil>"
 	if config.User != nil {
 		userVal = *config.User
 	}
-	parts = append(parts, fmt.Sprintf("user=%s", userVal))
+	parts = append(parts, fmt.Sprintf("%suser=%s", prefix, userVal))
 
 	portVal := "<nil>"
 	if config.Port != nil {
 		portVal = strconv.FormatInt(*config.Port, 10)
 	}
-	parts = append(parts, fmt.Sprintf("port=%s", portVal))
+	parts = append(parts, fmt.Sprintf("%sport=%s", prefix, portVal))
 
 	// Hash sensitive values
 	pwdVal := "<nil>"
 	if config.Password != nil {
 		pwdVal = hashSensitive(*config.Password)
 	}
-	parts = append(parts, fmt.Sprintf("pwd=%s", pwdVal))
+	parts = append(parts, fmt.Sprintf("%spwd=%s", prefix, pwdVal))
 
 	keyVal := "<nil>"
 	if config.PrivateKey != nil {
 		keyVal = hashSensitive(*config.PrivateKey)
 	}
-	parts = append(parts, fmt.Sprintf("key=%s", keyVal))
+	parts = append(parts, fmt.Sprintf("%skey=%s", prefix, keyVal))
 
-	// Add bastion flag
-	parts = append(parts, fmt.Sprintf("useProviderBastion=%v", useProviderAsBastion))
+	keyPathVal := "<nil>"
+	if config.PrivateKeyPath != nil {
+		keyPathVal = *config.PrivateKeyPath
+	}
+	parts = append(parts, fmt.Sprintf("%skey_path=%s", prefix, keyPathVal))
 
-	// Add bastion details if present
-	if bastion != nil {
-		hostVal := "<nil>"
-		if bastion.Host != nil {
-			hostVal = *bastion.Host
-		}
-		parts = append(parts, fmt.Sprintf("bastion_host=%s", hostVal))
+	pwdFileVal := "<nil>"
+	if config.PasswordFile != nil {
+		pwdFileVal = *config.PasswordFile
+	}
+	parts = append(parts, fmt.Sprintf("%spwd_file=%s", prefix, pwdFileVal))
 
-		userVal := "<nil>"
-		if bastion.User != nil {
-			userVal = *bastion.User
-		}
-		parts = append(parts, fmt.Sprintf("bastion_user=%s", userVal))
+	passphraseVal := "<nil>"
+	if config.PrivateKeyPassphrase != nil {
+		passphraseVal = hashSensitive(*config.PrivateKeyPassphrase)
+	}
+	parts = append(parts, fmt.Sprintf("%skey_passphrase=%s", prefix, passphraseVal))
 
-		portVal := "<nil>"
-		if bastion.Port != nil {
-			portVal = strconv.FormatInt(*bastion.Port, 10)
+	answersVal := "<nil>"
+	if config.KeyboardInteractiveAnswers != nil {
+		var answers []string
+		for key, answer := range config.KeyboardInteractiveAnswers {
+			answers = append(answers, key+"="+answer)
 		}
-		parts = append(parts, fmt.Sprintf("bastion_port=%s", portVal))
+		sort.Strings(answers)
+		answersVal = hashSensitive(strings.Join(answers, "\n"))
+	}
+	parts = append(parts, fmt.Sprintf("%skbd_answers=%s", prefix, answersVal))
 
-		// Hash sensitive bastion values
-		pwdVal := "<nil>"
-		if bastion.Password != nil {
-			pwdVal = hashSensitive(*bastion.Password)
-		}
-		parts = append(parts, fmt.Sprintf("bastion_pwd=%s", pwdVal))
+	totpVal := "<nil>"
+	if config.TOTPSecret != nil {
+		totpVal = hashSensitive(*config.TOTPSecret)
+	}
+	parts = append(parts, fmt.Sprintf("%stotp=%s", prefix, totpVal))
 
-		keyVal := "<nil>"
-		if bastion.PrivateKey != nil {
-			keyVal = hashSensitive(*bastion.PrivateKey)
-		}
-		parts = append(parts, fmt.Sprintf("bastion_key=%s", keyVal))
-	} else {
-		parts = append(parts, "bastion=<nil>")
+	certVal := "<nil>"
+	if config.Certificate != nil {
+		certVal = hashSensitive(*config.Certificate)
 	}
+	parts = append(parts, fmt.Sprintf("%scert=%s", prefix, certVal))
 
-	// Add fromClient address if present
-	if fromClient != nil {
-		parts = append(parts, fmt.Sprintf("from=%s", fromClient.RemoteAddr().String()))
-	} else {
-		parts = append(parts, "from=<nil>")
+	// Add agent settings
+	agentVal := "<nil>"
+	if config.Agent != nil {
+		agentVal = strconv.FormatBool(*config.Agent)
 	}
+	parts = append(parts, fmt.Sprintf("%sagent=%s", prefix, agentVal))
 
-	return connectionKey(strings.Join(parts, "|"))
+	agentSocketVal := "<nil>"
+	if config.AgentSocket != nil {
+		agentSocketVal = *config.AgentSocket
+	}
+	parts = append(parts, fmt.Sprintf("%sagent_socket=%s", prefix, agentSocketVal))
+
+	agentIdentityVal := "<nil>"
+	if config.AgentIdentity != nil {
+		agentIdentityVal = *config.AgentIdentity
+	}
+	parts = append(parts, fmt.Sprintf("%sagent_identity=%s", prefix, agentIdentityVal))
+
+	// Add host key verification settings
+	knownHostsVal := "<nil>"
+	if config.KnownHostsFiles != nil {
+		knownHostsVal = strings.Join(config.KnownHostsFiles, ",")
+	}
+	parts = append(parts, fmt.Sprintf("%sknown_hosts=%s", prefix, knownHostsVal))
+
+	hostKeysVal := "<nil>"
+	if config.HostKeys != nil {
+		hostKeysVal = hashSensitive(strings.Join(config.HostKeys, ","))
+	}
+	parts = append(parts, fmt.Sprintf("%shost_keys=%s", prefix, hostKeysVal))
+
+	policyVal := "<nil>"
+	if config.StrictHostKeyChecking != nil {
+		policyVal = *config.StrictHostKeyChecking
+	}
+	parts = append(parts, fmt.Sprintf("%sstrict_host_key_checking=%s", prefix, policyVal))
+
+	// Add proxy command
+	proxyCommandVal := "<nil>"
+	if config.ProxyCommand != nil {
+		proxyCommandVal = *config.ProxyCommand
+	}
+	parts = append(parts, fmt.Sprintf("%sproxy_command=%s", prefix, proxyCommandVal))
+
+	proxyVal := "<nil>"
+	if config.Proxy != nil {
+		proxyVal = fmt.Sprintf("%s://%s", config.Proxy.Type, config.Proxy.address())
+		if config.Proxy.Username != nil {
+			proxyVal += ",user=" + *config.Proxy.Username
+		}
+		if config.Proxy.Password != nil {
+			proxyVal += ",pwd=" + hashSensitive(*config.Proxy.Password)
+		}
+	}
+	parts = append(parts, fmt.Sprintf("%sproxy=%s", prefix, proxyVal))
+
+	// Add algorithm restrictions
+	for _, algorithms := range []struct {
+		name   string
+		values []string
+	}{
+		{"ciphers", config.Ciphers},
+		{"key_exchanges", config.KeyExchanges},
+		{"macs", config.MACs},
+		{"host_key_algorithms", config.HostKeyAlgorithms},
+	} {
+		algorithmsVal := "<nil>"
+		if algorithms.values != nil {
+			algorithmsVal = strings.Join(algorithms.values, ",")
+		}
+		parts = append(parts, fmt.Sprintf("%s%s=%s", prefix, algorithms.name, algorithmsVal))
+	}
+
+	return parts
+}
+
+// SSHManagerOptions holds provider-wide settings of the SSH manager
+type SSHManagerOptions struct {
+	// UseSSHConfig resolves hosts using the OpenSSH client configuration
+	UseSSHConfig bool
+	// SSHConfigFile overrides the OpenSSH client configuration file, defaults to ~/.ssh/config
+	SSHConfigFile *string
+	// ProxyFromEnvironment dials hosts through the proxy from ALL_PROXY, honoring NO_PROXY
+	ProxyFromEnvironment bool
+	// MaxSessionsPerHost limits the sessions open at the same time on each connection, zero means unlimited
+	MaxSessionsPerHost int64
+	// MaxSessions limits the sessions open at the same time across all connections, zero means unlimited
+	MaxSessions int64
+	// IdleTimeout closes connections without open sessions after this duration, zero disables it
+	IdleTimeout time.Duration
+	// Connections holds the named connections resources can select, keyed by name
+	Connections map[string]SSHConnectionProfile
+	// Become holds the default privilege escalation of commands and file operations
+	Become BecomeConfig
+}
+
+// SSHConnectionProfile is a named connection of the provider with its own bastion, jump hosts
+// and privilege escalation
+type SSHConnectionProfile struct {
+	Config    SSHConnectionConfig
+	JumpHosts []SSHConnectionConfig
+	Become    BecomeConfig
 }
 
 // SSHManager handles SSH connections for the provider
 type SSHManager struct {
-	providerConfig  *SSHConnectionConfig
-	providerBastion *SSHConnectionConfig
+	providerConfig    *SSHConnectionConfig
+	providerJumpHosts []SSHConnectionConfig
+	connections       map[string]SSHConnectionProfile
+	become            BecomeConfig
+	sshConfig         *ssh_config.Config
+
+	proxyFromEnvironment bool
+	sessions             *sessionLimiter
 
 	clientCache map[connectionKey]*ssh.Client
-	lockMap     sync.Map // Map of mutexes per connection key
+	clients     map[*ssh.Client]*managedClient // Lifecycle of every open connection
+	redials     map[*ssh.Client]redial         // How to get clients returned by GetClient again
+	cacheLock   sync.Mutex                     // Guards clientCache, clients and redials
+	lockMap     sync.Map                       // Map of mutexes per connection key
+
+	tunnels     map[string]*tunnel // Open local port forwards by ID
+	tunnelsLock sync.Mutex
+
+	done      chan struct{} // Closed when the manager is closed
+	closeOnce sync.Once
 }
 
 // getOrCreateLock returns a mutex for the given connection key
@@ -124,18 +276,95 @@ func (m *SSHManager) getOrCreateLock(key connectionKey) *sync.Mutex {
 	return actual.(*sync.Mutex)
 }
 
+// cachedClient returns the cached client for the given connection key and marks it as used
+func (m *SSHManager) cachedClient(key connectionKey) (*ssh.Client, bool) {
+	m.cacheLock.Lock()
+	defer m.cacheLock.Unlock()
+	client, ok := m.clientCache[key]
+	if ok {
+		m.touchClient(client)
+	}
+	return client, ok
+}
+
+// cacheClient stores the client under the given connection key
+func (m *SSHManager) cacheClient(key connectionKey, client *ssh.Client) {
+	m.cacheLock.Lock()
+	defer m.cacheLock.Unlock()
+	m.clientCache[key] = client
+}
+
+// evictClient removes the client cached under the given connection key
+func (m *SSHManager) evictClient(key connectionKey) {
+	m.cacheLock.Lock()
+	defer m.cacheLock.Unlock()
+	delete(m.clientCache, key)
+}
+
+// livenessTimeout returns how long to wait for a cached client to answer a liveness
+// check, which is the connection's connect_timeout when configured
+func (m *SSHManager) livenessTimeout(config SSHConnectionConfig) time.Duration {
+	config = config.withDefaults(m.providerConfig)
+	if config.ConnectTimeout != nil {
+		if timeout, err := time.ParseDuration(*config.ConnectTimeout); err == nil && timeout > 0 {
+			return timeout
+		}
+	}
+	return defaultLivenessTimeout
+}
+
 // NewSSHManager creates a new SSH connection manager
-func NewSSHManager(config *SSHConnectionConfig, bastion *SSHConnectionConfig) (*SSHManager, error) {
-	return &SSHManager{
-		providerConfig:  config,
-		providerBastion: bastion,
-		clientCache:     make(map[connectionKey]*ssh.Client),
-	}, nil
+func NewSSHManager(config *SSHConnectionConfig, jumpHosts []SSHConnectionConfig, options SSHManagerOptions) (*SSHManager, error) {
+	manager := &SSHManager{
+		providerConfig:       config,
+		providerJumpHosts:    jumpHosts,
+		connections:          options.Connections,
+		become:               options.Become,
+		proxyFromEnvironment: options.ProxyFromEnvironment,
+		sessions:             newSessionLimiter(options.MaxSessionsPerHost, options.MaxSessions),
+		clientCache:          make(map[connectionKey]*ssh.Client),
+		clients:              make(map[*ssh.Client]*managedClient),
+		redials:              make(map[*ssh.Client]redial),
+		tunnels:              make(map[string]*tunnel),
+		done:                 make(chan struct{}),
+	}
+
+	if options.UseSSHConfig || options.SSHConfigFile != nil {
+		sshConfig, err := loadSSHConfig(options.SSHConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		manager.sshConfig = sshConfig
+	}
+
+	if options.IdleTimeout > 0 {
+		go manager.evictIdleClients(options.IdleTimeout)
+	}
+
+	return manager, nil
 }
 
 // GetClient returns a cached SSH client or creates a new one if not found
-func (m *SSHManager) GetClient(config SSHConnectionConfig, useProviderAsBastion bool, bastion *SSHConnectionConfig, fromClient *ssh.Client) (*ssh.Client, error) {
-	key := newConnectionKey(config, useProviderAsBastion, bastion, fromClient)
+func (m *SSHManager) GetClient(ctx context.Context, config SSHConnectionConfig, useProviderAsBastion bool, jumpHosts []SSHConnectionConfig, fromClient *ssh.Client) (*ssh.Client, error) {
+	// Clients requested by resources are got again the same way once their connection closes
+	var dial dialFunc
+	if fromClient == nil {
+		requested, requestedJumpHosts := config, jumpHosts
+		dial = func(ctx context.Context) (*ssh.Client, error) {
+			return m.GetClient(ctx, requested, useProviderAsBastion, requestedJumpHosts, nil)
+		}
+	}
+
+	// Resolve the named connection, which stays part of the key so that clients are cached by name
+	if config.Connection != nil {
+		var err error
+		config, jumpHosts, err = m.resolveConnection(config, useProviderAsBastion, jumpHosts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key := newConnectionKey(config, useProviderAsBastion, jumpHosts, fromClient)
 	// fmt.Printf("ATTEMPTING_LOCK (cache_size=%d): %s\n", len(m.clientCache), key)
 
 	// Get or create lock for this connection key
@@ -143,17 +372,27 @@ func (m *SSHManager) GetClient(config SSHConnectionConfig, useProviderAsBastion
 	lock.Lock()
 	// fmt.Printf("ACQUIRED_LOCK: %s\n", key)
 
-	// Check if client exists in cache
-	if client, ok := m.clientCache[key]; ok {
-		// fmt.Printf("CACHE_HIT: %s\n", key)
-		lock.Unlock()
-		// fmt.Printf("RELEASED_LOCK: %s\n", key)
-		return client, nil
+	// Check if client exists in cache and is still alive
+	if client, ok := m.cachedClient(key); ok {
+		if clientAlive(client, m.livenessTimeout(config)) {
+			// fmt.Printf("CACHE_HIT: %s\n", key)
+			lock.Unlock()
+			// fmt.Printf("RELEASED_LOCK: %s\n", key)
+			if dial != nil {
+				m.rememberDial(key, client, dial)
+			}
+			return client, nil
+		}
+
+		// Evict the dead client, reconnecting below also re-dials dead bastion parents
+		tflog.Info(ctx, fmt.Sprintf("Cached SSH connection to %s is no longer alive, reconnecting", hostDescription(config)))
+		client.Close()
+		m.evictClient(key)
 	}
 	// fmt.Printf("CACHE_MISS: %s\n", key)
 
 	// Create new client
-	client, isNew, err := m.getClient(config, useProviderAsBastion, bastion, fromClient)
+	client, isNew, err := m.getClient(ctx, config, useProviderAsBastion, jumpHosts, fromClient)
 	if err != nil {
 		lock.Unlock()
 		// fmt.Printf("RELEASED_LOCK (error): %s\n", key)
@@ -162,7 +401,7 @@ func (m *SSHManager) GetClient(config SSHConnectionConfig, useProviderAsBastion
 
 	// Cache the new client only if it was newly created
 	if isNew {
-		m.clientCache[key] = client
+		m.cacheClient(key, client)
 		// fmt.Printf("CACHED_NEW_CLIENT: %s\n", key)
 	} else {
 		// fmt.Printf("CACHE_MISS_NEW_CLIENT: %s\n", key)
@@ -170,56 +409,87 @@ func (m *SSHManager) GetClient(config SSHConnectionConfig, useProviderAsBastion
 
 	lock.Unlock()
 	// fmt.Printf("RELEASED_LOCK: %s\n", key)
+	if dial != nil {
+		m.rememberDial(key, client, dial)
+	}
 	return client, nil
 }
 
+// resolveConnection applies the config on top of the named connection it refers to. The
+// bastion and jump hosts of the named connection are used unless others are given.
+func (m *SSHManager) resolveConnection(config SSHConnectionConfig, useProviderAsBastion bool, jumpHosts []SSHConnectionConfig) (SSHConnectionConfig, []SSHConnectionConfig, error) {
+	name := *config.Connection
+	profile, ok := m.connections[name]
+	if !ok {
+		names := make([]string, 0, len(m.connections))
+		for defined := range m.connections {
+			names = append(names, fmt.Sprintf("%q", defined))
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return config, nil, fmt.Errorf("connection %q is not defined, the provider has no connections", name)
+		}
+		return config, nil, fmt.Errorf("connection %q is not defined, the provider's connections are %s", name, strings.Join(names, ", "))
+	}
+
+	resolved := profile.Config.withOverrides(config)
+	if resolved.Host == nil {
+		return config, nil, fmt.Errorf("connection %q has no host", name)
+	}
+	if !useProviderAsBastion && len(jumpHosts) == 0 {
+		jumpHosts = profile.JumpHosts
+	}
+	return resolved, jumpHosts, nil
+}
+
 // getClient is the internal implementation that creates new SSH clients
-func (m *SSHManager) getClient(config SSHConnectionConfig, useProviderAsBastion bool, bastion *SSHConnectionConfig, fromClient *ssh.Client) (*ssh.Client, bool, error) {
+func (m *SSHManager) getClient(ctx context.Context, config SSHConnectionConfig, useProviderAsBastion bool, jumpHosts []SSHConnectionConfig, fromClient *ssh.Client) (*ssh.Client, bool, error) {
+	// The named connection has already been resolved by GetClient
+	config.Connection = nil
+
 	// If useProviderAsBastion is true, use the provider client as bastion
 	if useProviderAsBastion {
-		providerClient, err := m.GetClient(*m.providerConfig, false, m.providerBastion, nil)
+		providerClient, err := m.GetClient(ctx, *m.providerConfig, false, m.providerJumpHosts, nil)
 		if err != nil {
 			return nil, false, fmt.Errorf("failed to connect to provider as bastion: %w", err)
 		}
-		client, err := m.GetClient(config, false, bastion, providerClient)
+		client, err := m.GetClient(ctx, config, false, jumpHosts, providerClient)
 		return client, false, err
 	}
 
-	// If bastion is provided, get bastion client and recurse
-	if bastion != nil {
-		bastionClient, err := m.GetClient(*bastion, false, nil, fromClient)
+	// If jump hosts are provided, dial through them and recurse
+	if len(jumpHosts) > 0 {
+		hopClient, err := m.dialJumpHosts(ctx, jumpHosts, fromClient)
 		if err != nil {
-			return nil, false, fmt.Errorf("failed to connect to bastion: %w", err)
+			return nil, false, err
 		}
-		client, err := m.GetClient(config, false, nil, bastionClient)
+		client, err := m.GetClient(ctx, config, false, nil, hopClient)
 		return client, true, err
 	}
 
 	// If there is no configuration, fall back to provider client
 	if fromClient == nil && config.Host == nil {
-		providerClient, err := m.GetClient(*m.providerConfig, false, m.providerBastion, nil)
+		providerClient, err := m.GetClient(ctx, *m.providerConfig, false, m.providerJumpHosts, nil)
 		if err != nil {
 			return nil, false, fmt.Errorf("failed to connect to provider: %w", err)
 		}
 		return providerClient, true, nil
 	}
 
-	// Create ssh client configuration
-	sshConfig := &ssh.ClientConfig{
-		User:            *config.User,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	// Inherit the provider's defaults, then resolve host aliases, remaining defaults and
+	// jump hosts from the OpenSSH client configuration
+	config = config.withDefaults(m.providerConfig)
+	config, proxyJumpHosts, err := applySSHConfig(m.sshConfig, config)
+	if err != nil {
+		return nil, false, err
 	}
 
-	// Configure authentication
-	if config.Password != nil {
-		sshConfig.Auth = append(sshConfig.Auth, ssh.Password(*config.Password))
-	}
-	if config.PrivateKey != nil {
-		signer, err := ssh.ParsePrivateKey([]byte(*config.PrivateKey))
+	// ProxyJump only applies when the host is not already reached through a bastion
+	if fromClient == nil && config.ProxyCommand == nil && len(proxyJumpHosts) > 0 {
+		fromClient, err = m.dialJumpHosts(ctx, proxyJumpHosts, nil)
 		if err != nil {
-			return nil, false, fmt.Errorf("unable to parse private key: %w", err)
+			return nil, false, err
 		}
-		sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeys(signer))
 	}
 
 	// Create target from port and host
@@ -229,23 +499,177 @@ func (m *SSHManager) getClient(config SSHConnectionConfig, useProviderAsBastion
 	}
 	target := net.JoinHostPort(*config.Host, strconv.FormatInt(port, 10))
 
-	// If there is no fromClient, return a new client using ssh.Dial
-	if fromClient == nil {
-		client, err := ssh.Dial("tcp", target, sshConfig)
+	if config.User == nil {
+		return nil, false, fmt.Errorf("no user is configured for %s, set user or take it from the OpenSSH client configuration with use_ssh_config", target)
+	}
+
+	// Verify host keys using the connection's settings
+	hostKeyCallback, hostKeyAlgorithms, err := newHostKeyCallback(config, target)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to configure host key verification for %s: %w", target, err)
+	}
+
+	// Configure authentication
+	config, err = readCredentialFiles(config)
+	if err != nil {
+		return nil, false, err
+	}
+	authMethods, closeAuth, err := newAuthMethods(config)
+	if err != nil {
+		return nil, false, err
+	}
+	defer closeAuth()
+
+	// Create ssh client configuration
+	sshConfig := &ssh.ClientConfig{
+		User:              *config.User,
+		Auth:              authMethods,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+	}
+	if err := applyAlgorithms(sshConfig, config, target); err != nil {
+		return nil, false, err
+	}
+
+	// Proxy commands are run locally, so they can only be used for the first hop
+	if config.ProxyCommand != nil && fromClient != nil {
+		return nil, false, fmt.Errorf("proxy_command cannot be used for %s, which is reached through a bastion or jump host", target)
+	}
+
+	// Connect, retrying transient failures such as a host that is still booting
+	policy, err := newConnectPolicy(config)
+	if err != nil {
+		return nil, false, err
+	}
+	keepalive, err := newKeepalivePolicy(config)
+	if err != nil {
+		return nil, false, err
+	}
+	client, err := policy.retry(ctx, target, func() (*ssh.Client, error) {
+		return m.connect(config, target, port, sshConfig, fromClient, policy.timeout)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Keep the connection alive and detect when it breaks
+	keepalive.start(client)
+	m.trackClient(client, fromClient)
+
+	return client, true, nil
+}
+
+// connect makes a single attempt to open the transport to the target and run the SSH
+// handshake over it, within the given timeout
+func (m *SSHManager) connect(config SSHConnectionConfig, target string, port int64, sshConfig *ssh.ClientConfig, fromClient *ssh.Client, timeout time.Duration) (*ssh.Client, error) {
+	start := time.Now()
+	closeConn := func(conn net.Conn) { conn.Close() }
+
+	var conn net.Conn
+	var proxyConn *proxyCommandConn
+	var err error
+	switch {
+	case config.ProxyCommand != nil:
+		// Run the handshake over the stdin and stdout of the proxy command
+		command := expandProxyCommand(*config.ProxyCommand, *config.Host, port, *config.User)
+		proxyConn, err = dialProxyCommand(command, target)
+		if err != nil {
+			return nil, err
+		}
+		conn = proxyConn
+	case fromClient == nil:
+		// Dial the target directly or through the configured proxy
+		proxyConfig := config.Proxy
+		if proxyConfig == nil && m.proxyFromEnvironment {
+			proxyConfig, err = proxyFromEnvironment(target)
+			if err != nil {
+				return nil, err
+			}
+		}
+		conn, err = withTimeout(timeout, func() (net.Conn, error) { return dialThroughProxy(proxyConfig, target) }, closeConn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to target host: %w", err)
+		}
+	default:
+		// Create new connection through fromClient
+		conn, err = withTimeout(timeout, func() (net.Conn, error) { return fromClient.Dial("tcp", target) }, closeConn)
 		if err != nil {
-			return nil, false, fmt.Errorf("failed to connect to target host: %w", err)
+			return nil, fmt.Errorf("failed to connect to target host through bastion: %w", err)
 		}
-		return client, true, nil
 	}
 
-	// Create new client through fromClient
-	conn, err := fromClient.Dial("tcp", target)
+	ncc, chans, reqs, err := handshake(conn, target, sshConfig, timeout, start)
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to connect to target host through bastion: %w", err)
+		conn.Close()
+		err = annotateAuthError(err, config)
+		switch {
+		case proxyConn != nil:
+			return nil, fmt.Errorf("unable to create SSH connection through proxy command: %w", proxyConn.annotateError(err))
+		case fromClient == nil:
+			return nil, fmt.Errorf("failed to connect to target host: %w", err)
+		default:
+			return nil, fmt.Errorf("unable to create SSH connection through bastion: %w", err)
+		}
+	}
+	client := ssh.NewClient(ncc, chans, reqs)
+
+	// Tie the proxy command to the client, so that it is terminated when the client is
+	// closed and reaped when the connection breaks
+	if proxyConn != nil {
+		go func() {
+			client.Wait()
+			proxyConn.Close()
+		}()
 	}
+
+	return client, nil
+}
+
+// handshake runs the SSH handshake over conn, closing it when the timeout counted from
+// start expires first. A zero timeout waits indefinitely.
+func handshake(conn net.Conn, target string, sshConfig *ssh.ClientConfig, timeout time.Duration, start time.Time) (ssh.Conn, <-chan ssh.NewChannel, <-chan *ssh.Request, error) {
+	if timeout <= 0 {
+		return ssh.NewClientConn(conn, target, sshConfig)
+	}
+
+	remaining := max(timeout-time.Since(start), time.Nanosecond)
+	timer := time.AfterFunc(remaining, func() { conn.Close() })
 	ncc, chans, reqs, err := ssh.NewClientConn(conn, target, sshConfig)
-	if err != nil {
-		return nil, false, fmt.Errorf("unable to create SSH connection through bastion: %w", err)
+	if !timer.Stop() {
+		if err == nil {
+			ncc.Close()
+		}
+		return nil, nil, nil, fmt.Errorf("ssh: handshake failed: %w after %s", errConnectTimeout, timeout)
+	}
+	return ncc, chans, reqs, err
+}
+
+// dialJumpHosts connects to each jump host through the previous one, starting from
+// fromClient, and returns the client of the last hop. Every intermediate client is
+// cached under its own key.
+func (m *SSHManager) dialJumpHosts(ctx context.Context, jumpHosts []SSHConnectionConfig, fromClient *ssh.Client) (*ssh.Client, error) {
+	hopClient := fromClient
+	for i, hop := range jumpHosts {
+		client, err := m.GetClient(ctx, hop, false, nil, hopClient)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to jump host %d of %d (%s): %w", i+1, len(jumpHosts), hostDescription(hop), err)
+		}
+		hopClient = client
+	}
+	return hopClient, nil
+}
+
+// hostDescription returns a human readable user@host:port description of a connection
+func hostDescription(config SSHConnectionConfig) string {
+	description := "<provider host>"
+	if config.Host != nil {
+		description = *config.Host
+	}
+	if config.Port != nil {
+		description = net.JoinHostPort(description, strconv.FormatInt(*config.Port, 10))
+	}
+	if config.User != nil {
+		description = *config.User + "@" + description
 	}
-	return ssh.NewClient(ncc, chans, reqs), true, nil
+	return description
 }
@@ -2,42 +2,217 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
 	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 type SSHProviderModel struct {
 	SSHConnectionModel
-	Bastion *SSHConnectionModel `tfsdk:"bastion"`
+	SSHBecomeModel
+	Bastion               *SSHConnectionModel                  `tfsdk:"bastion"`
+	JumpHosts             []SSHConnectionModel                 `tfsdk:"jump_hosts"`
+	UseSSHConfig          types.Bool                           `tfsdk:"use_ssh_config"`
+	SSHConfigFile         types.String                         `tfsdk:"ssh_config_file"`
+	ProxyFromEnvironment  types.Bool                           `tfsdk:"proxy_from_environment"`
+	MaxSessionsPerHost    types.Int64                          `tfsdk:"max_sessions_per_host"`
+	MaxSessions           types.Int64                          `tfsdk:"max_sessions"`
+	ConnectionIdleTimeout types.String                         `tfsdk:"connection_idle_timeout"`
+	Connections           map[string]SSHConnectionProfileModel `tfsdk:"connections"`
+}
+
+// SSHConnectionProfileModel is a named connection with its own bastion, jump hosts and
+// privilege escalation
+type SSHConnectionProfileModel struct {
+	SSHConnectionModel
+	SSHBecomeModel
+	Bastion   *SSHConnectionModel  `tfsdk:"bastion"`
+	JumpHosts []SSHConnectionModel `tfsdk:"jump_hosts"`
 }
 
 var SSHProviderSchema = schema.Schema{
+	Description: "Runs commands and manages files over SSH. Every attribute that is not configured falls back to an environment variable named after it, such as SSH_HOST, SSH_USER, SSH_PASSWORD or SSH_BASTION_HOST. Certificates can also be read from the files named by SSH_CERTIFICATE_PATH and SSH_BASTION_CERTIFICATE_PATH",
 	Attributes: map[string]schema.Attribute{
-		"host":        schema.StringAttribute{Description: "The hostname or IP address of the target SSH server", Optional: true},
-		"port":        schema.Int64Attribute{Description: "The port number of the target SSH server", Optional: true},
-		"user":        schema.StringAttribute{Description: "The username for SSH authentication", Optional: true},
-		"password":    schema.StringAttribute{Description: "The password for SSH authentication", Optional: true, Sensitive: true},
-		"private_key": schema.StringAttribute{Description: "The private key for SSH authentication", Optional: true, Sensitive: true},
+		"host":          schema.StringAttribute{Description: "The hostname or IP address of the target SSH server", Optional: true},
+		"port":          schema.Int64Attribute{Description: "The port number of the target SSH server", Optional: true, Validators: portValidators},
+		"proxy_command": schema.StringAttribute{Description: "Local command used to reach the host, such as a cloud session manager or `nc` through a corporate proxy, whose stdin and stdout carry the SSH connection. The tokens %h, %p and %r are replaced by the host, port and user", Optional: true},
+		"proxy": schema.SingleNestedAttribute{
+			Description: "SOCKS5 or HTTP CONNECT proxy used to reach the host, or the first bastion or jump host. Inherited by resources and bastions that do not configure their own proxy",
+			Optional:    true,
+			Attributes: map[string]schema.Attribute{
+				"type":     SSHProxySchema.Type,
+				"host":     SSHProxySchema.Host,
+				"port":     SSHProxySchema.Port,
+				"username": SSHProxySchema.Username,
+				"password": SSHProxySchema.Password,
+			},
+		},
+		"max_sessions_per_host": schema.Int64Attribute{
+			Description: "Maximum number of sessions (commands and SFTP clients) open at the same time on each connection, to stay below the MaxSessions limit of sshd. Defaults to 10, 0 means unlimited",
+			Optional:    true,
+			Validators:  []validator.Int64{int64validator.AtLeast(0)},
+		},
+		"max_sessions": schema.Int64Attribute{
+			Description: "Maximum number of sessions open at the same time across all connections. Defaults to unlimited",
+			Optional:    true,
+			Validators:  []validator.Int64{int64validator.AtLeast(0)},
+		},
+		"connection_idle_timeout": schema.StringAttribute{
+			Description: "Close connections that have had no open sessions for this long (e.g. '10m'). Bastions are closed once the last connection through them is closed. Connections are re-established when needed again. Defaults to keeping connections open until the provider exits",
+			Optional:    true,
+			Validators:  durationValidators,
+		},
+		"proxy_from_environment": schema.BoolAttribute{
+			Description: "Use the proxy from the ALL_PROXY environment variable for hosts not excluded by NO_PROXY, unless a proxy or proxy_command is configured",
+			Optional:    true,
+		},
+		"user":                         schema.StringAttribute{Description: "The username for SSH authentication", Optional: true},
+		"password":                     schema.StringAttribute{Description: "The password for SSH authentication", Optional: true, Sensitive: true},
+		"private_key":                  schema.StringAttribute{Description: "The private key for SSH authentication", Optional: true, Sensitive: true},
+		"private_key_passphrase":       schema.StringAttribute{Description: "The passphrase used to decrypt an encrypted private key", Optional: true, Sensitive: true},
+		"private_key_path":             schema.StringAttribute{Description: "Path of a local file holding the private key for SSH authentication (e.g. '~/.ssh/id_ed25519'), read at connect time so that only the path is stored in state", Optional: true, Validators: privateKeyPathValidators},
+		"password_file":                schema.StringAttribute{Description: "Path of a local file holding the password for SSH authentication, read at connect time so that only the path is stored in state. A trailing newline is ignored", Optional: true, Validators: passwordFileValidators},
+		"keyboard_interactive_answers": schema.MapAttribute{Description: "Static answers to keyboard-interactive prompts, keyed by a case-insensitive substring of the prompt", Optional: true, Sensitive: true, ElementType: types.StringType},
+		"totp_secret":                  schema.StringAttribute{Description: "The base32 TOTP secret used to generate codes for one-time password prompts during keyboard-interactive authentication", Optional: true, Sensitive: true},
+		"certificate":                  schema.StringAttribute{Description: "The OpenSSH user certificate (e.g. the contents of id_ed25519-cert.pub) used together with private_key or the ssh-agent identity holding its key", Optional: true},
+		"agent":                        schema.BoolAttribute{Description: "Authenticate using the identities of the running ssh-agent", Optional: true},
+		"agent_socket":                 schema.StringAttribute{Description: "Path to the ssh-agent socket. Defaults to SSH_AUTH_SOCK", Optional: true},
+		"agent_identity":               schema.StringAttribute{Description: "Only use the ssh-agent identity matching this comment, SHA256 fingerprint or public key", Optional: true},
+		"known_hosts_files": schema.ListAttribute{
+			Description: "The known_hosts files used to verify host keys. Defaults to ~/.ssh/known_hosts, which is only read: new host keys are only recorded in known_hosts files configured explicitly",
+			Optional:    true,
+			ElementType: types.StringType,
+		},
+		"host_keys": schema.ListAttribute{
+			Description: "Pinned host public keys (authorized_keys format) or SHA256 fingerprints the target host must present",
+			Optional:    true,
+			ElementType: types.StringType,
+		},
+		"strict_host_key_checking": schema.StringAttribute{
+			Description: "The host key checking policy: 'strict' rejects unknown hosts, 'accept-new' trusts unknown hosts on first use and records them in the first known_hosts file when known_hosts_files is set (otherwise only while the provider runs), 'off' disables verification. Defaults to 'accept-new', or 'strict' when host_keys are set",
+			Optional:    true,
+			Validators:  hostKeyPolicyValidators,
+		},
+		"connect_timeout":            schema.StringAttribute{Description: "Maximum time to establish each connection, including the SSH handshake (e.g. '30s'). Applies to every hop. Defaults to no timeout", Optional: true, Validators: durationValidators},
+		"connect_retries":            schema.Int64Attribute{Description: "Number of times a connection attempt failing with a transient error, such as a refused connection while the host boots, is retried. Authentication failures are never retried. Defaults to 0, or unlimited when connect_retry_timeout is set", Optional: true, Validators: []validator.Int64{int64validator.AtLeast(0)}},
+		"connect_retry_timeout":      schema.StringAttribute{Description: "Maximum time spent retrying connection attempts failing with a transient error (e.g. '5m')", Optional: true, Validators: durationValidators},
+		"connect_retry_interval":     schema.StringAttribute{Description: "Delay before the first retry of a failed connection attempt, doubled after every retry. Defaults to '1s'", Optional: true, Validators: durationValidators},
+		"connect_retry_max_interval": schema.StringAttribute{Description: "Maximum delay between retries of failed connection attempts. Defaults to '30s'", Optional: true, Validators: durationValidators},
+		"keepalive_interval":         schema.StringAttribute{Description: "Interval between keepalive requests sent over each connection (e.g. '30s'), which keeps NAT and firewall state alive and detects broken connections. Defaults to no keepalives", Optional: true, Validators: durationValidators},
+		"keepalive_count_max":        schema.Int64Attribute{Description: "Number of consecutive unanswered keepalive requests after which a connection is considered dead and closed. Defaults to 3", Optional: true, Validators: []validator.Int64{int64validator.AtLeast(1)}},
+		"ciphers":                    schema.ListAttribute{Description: "Ciphers allowed for connections, in order of preference. Defaults to the secure ciphers enabled by golang.org/x/crypto/ssh", Optional: true, ElementType: types.StringType, Validators: cipherValidators},
+		"key_exchanges":              schema.ListAttribute{Description: "Key exchange algorithms allowed for connections, in order of preference. Defaults to the secure algorithms enabled by golang.org/x/crypto/ssh", Optional: true, ElementType: types.StringType, Validators: keyExchangeValidators},
+		"macs":                       schema.ListAttribute{Description: "MAC algorithms allowed for connections, in order of preference. Defaults to the secure algorithms enabled by golang.org/x/crypto/ssh", Optional: true, ElementType: types.StringType, Validators: macValidators},
+		"host_key_algorithms":        schema.ListAttribute{Description: "Host key algorithms accepted from servers, in order of preference. Defaults to the algorithms matching the known host keys, or those enabled by golang.org/x/crypto/ssh", Optional: true, ElementType: types.StringType, Validators: hostKeyAlgorithmValidators},
+		"become":                     schema.BoolAttribute{Description: "Run commands and file operations as become_user, escalating privileges with become_method. File operations then run as commands instead of over SFTP. Defaults to false", Optional: true},
+		"become_user":                schema.StringAttribute{Description: "The user commands and file operations run as when become is set. Defaults to 'root'", Optional: true},
+		"become_method":              schema.StringAttribute{Description: "The privilege escalation method: 'sudo', 'su' or 'doas'. Defaults to 'sudo'", Optional: true, Validators: becomeMethodValidators},
+		"become_password":            schema.StringAttribute{Description: "The password answering the prompt of the privilege escalation method, written to it only when it prompts. Without it, the method must not prompt (e.g. NOPASSWD in sudoers)", Optional: true, Sensitive: true},
+		"use_ssh_config": schema.BoolAttribute{
+			Description: "Resolve hosts using the OpenSSH client configuration (HostName, User, Port, IdentityFile, ProxyJump, StrictHostKeyChecking and UserKnownHostsFile). Explicitly configured attributes take precedence. ProxyJump hosts take their user from user@host or their own Host block, never from the target host",
+			Optional:    true,
+		},
+		"ssh_config_file": schema.StringAttribute{
+			Description: "Path to the OpenSSH client configuration file. Defaults to ~/.ssh/config. Setting this implies use_ssh_config",
+			Optional:    true,
+		},
 		"bastion": schema.SingleNestedAttribute{
 			Description: "Bastion host configuration",
 			Optional:    true,
-			Attributes: map[string]schema.Attribute{
-				"host":        SSHConnectionSchema.Host,
-				"port":        SSHConnectionSchema.Port,
-				"user":        SSHConnectionSchema.User,
-				"password":    SSHConnectionSchema.Password,
-				"private_key": SSHConnectionSchema.PrivateKey,
+			Attributes:  providerBastionAttributes,
+		},
+		"jump_hosts": schema.ListNestedAttribute{
+			Description: "Ordered list of jump hosts, dialed hop by hop after the bastion (if any) to reach the target host",
+			Optional:    true,
+			NestedObject: schema.NestedAttributeObject{
+				Attributes: providerBastionAttributes,
+			},
+		},
+		"connections": schema.MapNestedAttribute{
+			Description: "Named connections, selected by resources and data sources with their connection attribute. Each connection can have its own bastion and jump hosts, and inherits the provider's defaults like a bastion does",
+			Optional:    true,
+			NestedObject: schema.NestedAttributeObject{
+				Attributes: providerConnectionAttributes,
 			},
 		},
 	},
 }
 
+// providerBastionAttributes are the attributes of a bastion or jump host of the provider
+var providerBastionAttributes = map[string]schema.Attribute{
+	"host":                         SSHConnectionSchema.Host,
+	"port":                         SSHConnectionSchema.Port,
+	"proxy_command":                SSHConnectionSchema.ProxyCommand,
+	"proxy":                        SSHConnectionSchema.Proxy,
+	"user":                         SSHConnectionSchema.User,
+	"password":                     SSHConnectionSchema.Password,
+	"private_key":                  SSHConnectionSchema.PrivateKey,
+	"private_key_passphrase":       SSHConnectionSchema.PrivateKeyPassphrase,
+	"private_key_path":             SSHConnectionSchema.PrivateKeyPath,
+	"password_file":                SSHConnectionSchema.PasswordFile,
+	"keyboard_interactive_answers": SSHConnectionSchema.KeyboardInteractiveAnswers,
+	"totp_secret":                  SSHConnectionSchema.TOTPSecret,
+	"certificate":                  SSHConnectionSchema.Certificate,
+	"agent":                        SSHConnectionSchema.Agent,
+	"agent_socket":                 SSHConnectionSchema.AgentSocket,
+	"agent_identity":               SSHConnectionSchema.AgentIdentity,
+	"known_hosts_files":            SSHConnectionSchema.KnownHostsFiles,
+	"host_keys":                    SSHConnectionSchema.HostKeys,
+	"strict_host_key_checking":     SSHConnectionSchema.StrictHostKeyChecking,
+	"connect_timeout":              SSHConnectionSchema.ConnectTimeout,
+	"connect_retries":              SSHConnectionSchema.ConnectRetries,
+	"connect_retry_timeout":        SSHConnectionSchema.ConnectRetryTimeout,
+	"connect_retry_interval":       SSHConnectionSchema.ConnectRetryInterval,
+	"connect_retry_max_interval":   SSHConnectionSchema.ConnectRetryMaxInterval,
+	"keepalive_interval":           SSHConnectionSchema.KeepaliveInterval,
+	"keepalive_count_max":          SSHConnectionSchema.KeepaliveCountMax,
+	"ciphers":                      SSHConnectionSchema.Ciphers,
+	"key_exchanges":                SSHConnectionSchema.KeyExchanges,
+	"macs":                         SSHConnectionSchema.MACs,
+	"host_key_algorithms":          SSHConnectionSchema.HostKeyAlgorithms,
+}
+
+// providerConnectionAttributes are the attributes of a named connection, which are those of
+// a bastion together with its own bastion, jump hosts and privilege escalation
+var providerConnectionAttributes = func() map[string]schema.Attribute {
+	attributes := map[string]schema.Attribute{
+		"bastion": schema.SingleNestedAttribute{
+			Description: "Bastion host configuration of the connection",
+			Optional:    true,
+			Attributes:  providerBastionAttributes,
+		},
+		"jump_hosts": schema.ListNestedAttribute{
+			Description: "Ordered list of jump hosts of the connection, dialed hop by hop after its bastion (if any)",
+			Optional:    true,
+			NestedObject: schema.NestedAttributeObject{
+				Attributes: providerBastionAttributes,
+			},
+		},
+		"become":          SSHBecomeSchema.Become,
+		"become_user":     SSHBecomeSchema.BecomeUser,
+		"become_method":   SSHBecomeSchema.BecomeMethod,
+		"become_password": SSHBecomeSchema.BecomePassword,
+	}
+	for name, attribute := range providerBastionAttributes {
+		attributes[name] = attribute
+	}
+	return attributes
+}()
+
 var _ provider.Provider = &SSHProvider{}
+var _ provider.ProviderWithValidateConfig = &SSHProvider{}
+var _ provider.ProviderWithEphemeralResources = &SSHProvider{}
 
 type SSHProvider struct {
 	version string
@@ -53,6 +228,49 @@ func (p *SSHProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *
 	resp.Schema = SSHProviderSchema
 }
 
+func (p *SSHProvider) ValidateConfig(ctx context.Context, req provider.ValidateConfigRequest, resp *provider.ValidateConfigResponse) {
+	var config SSHProviderModel
+
+	// Nested objects that are not known yet are validated once they are
+	if diags := req.Config.Get(ctx, &config); diags.HasError() {
+		return
+	}
+
+	// Attributes that are not configured may still be set by environment variables
+	if err := applyEnvDefaults(&config, "", make(map[string]string)); err != nil {
+		resp.Diagnostics.AddError(
+			"Invalid environment variable",
+			err.Error(),
+		)
+		return
+	}
+
+	// Users and credentials can also come from the OpenSSH client configuration
+	useSSHConfig := config.UseSSHConfig.ValueBool() || config.UseSSHConfig.IsUnknown() || !config.SSHConfigFile.IsNull()
+	checkCredentials := !useSSHConfig
+
+	if !config.Host.IsNull() && checkCredentials {
+		validateUser(path.Empty(), &config.SSHConnectionModel, "the provider's host", &resp.Diagnostics)
+	}
+	validateHops(path.Root("bastion"), path.Root("jump_hosts"), config.Bastion, config.JumpHosts, checkCredentials, &resp.Diagnostics)
+
+	for name, connection := range config.Connections {
+		connectionPath := path.Root("connections").AtMapKey(name)
+		if connection.Host.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				connectionPath.AtName("host"),
+				"Missing host",
+				fmt.Sprintf("Connection %q has no host. Set host to the address of the server to connect to.", name),
+			)
+			continue
+		}
+		if checkCredentials {
+			validateUser(connectionPath, &connection.SSHConnectionModel, fmt.Sprintf("connection %q", name), &resp.Diagnostics)
+		}
+		validateHops(connectionPath.AtName("bastion"), connectionPath.AtName("jump_hosts"), connection.Bastion, connection.JumpHosts, checkCredentials, &resp.Diagnostics)
+	}
+}
+
 func (p *SSHProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
 	var config SSHProviderModel
 
@@ -61,13 +279,66 @@ func (p *SSHProvider) Configure(ctx context.Context, req provider.ConfigureReque
 		return
 	}
 
+	// Fall back to the environment for attributes that are not configured
+	sources := make(map[string]string)
+	if err := applyEnvDefaults(&config, "", sources); err != nil {
+		resp.Diagnostics.AddError(
+			"Invalid environment variable",
+			err.Error(),
+		)
+		return
+	}
+	fields := make(map[string]interface{}, len(sources))
+	for attrPath, source := range sources {
+		fields[attrPath] = source
+	}
+	tflog.Debug(ctx, "Resolved provider configuration sources", fields)
+
 	// Set default port if not specified
 	if config.Port.IsNull() {
 		config.Port = types.Int64Value(22)
 	}
 
 	// Create the SSH manager with provider configuration
-	manager, err := NewSSHManager(config.SSHConnectionModel.toConfig(), config.Bastion.toConfig())
+	options := SSHManagerOptions{
+		UseSSHConfig:         config.UseSSHConfig.ValueBool(),
+		ProxyFromEnvironment: config.ProxyFromEnvironment.ValueBool(),
+		MaxSessionsPerHost:   defaultMaxSessionsPerHost,
+		MaxSessions:          config.MaxSessions.ValueInt64(),
+		Become:               config.SSHBecomeModel.toConfig(),
+	}
+	if !config.SSHConfigFile.IsNull() {
+		value := config.SSHConfigFile.ValueString()
+		options.SSHConfigFile = &value
+	}
+	if !config.MaxSessionsPerHost.IsNull() {
+		options.MaxSessionsPerHost = config.MaxSessionsPerHost.ValueInt64()
+	}
+	if !config.ConnectionIdleTimeout.IsNull() {
+		idleTimeout, err := time.ParseDuration(config.ConnectionIdleTimeout.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("connection_idle_timeout"),
+				"Invalid connection idle timeout",
+				err.Error(),
+			)
+			return
+		}
+		options.IdleTimeout = idleTimeout
+	}
+
+	if len(config.Connections) > 0 {
+		options.Connections = make(map[string]SSHConnectionProfile, len(config.Connections))
+		for name, connection := range config.Connections {
+			options.Connections[name] = SSHConnectionProfile{
+				Config:    *connection.SSHConnectionModel.toConfig(),
+				JumpHosts: jumpHostConfigs(connection.Bastion, connection.JumpHosts),
+				Become:    connection.SSHBecomeModel.toConfig(),
+			}
+		}
+	}
+
+	manager, err := NewSSHManager(config.SSHConnectionModel.toConfig(), jumpHostConfigs(config.Bastion, config.JumpHosts), options)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Unable to create SSH manager",
@@ -76,9 +347,17 @@ func (p *SSHProvider) Configure(ctx context.Context, req provider.ConfigureReque
 		return
 	}
 
+	// A provider configured again replaces its manager, whose connections are closed
+	if p.manager != nil {
+		unregisterManager(p.manager)
+		p.manager.Close()
+	}
+	registerManager(manager)
+
 	p.manager = manager
 	resp.DataSourceData = p.manager
 	resp.ResourceData = p.manager
+	resp.EphemeralResourceData = p.manager
 }
 
 func (p *SSHProvider) Resources(ctx context.Context) []func() resource.Resource {
@@ -95,6 +374,12 @@ func (p *SSHProvider) DataSources(ctx context.Context) []func() datasource.DataS
 	}
 }
 
+func (p *SSHProvider) EphemeralResources(ctx context.Context) []func() ephemeral.EphemeralResource {
+	return []func() ephemeral.EphemeralResource{
+		NewSSHTunnelEphemeralResource,
+	}
+}
+
 func (p *SSHProvider) Functions(ctx context.Context) []func() function.Function {
 	return []func() function.Function{}
 }
@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// Default proxy ports
+const (
+	defaultSOCKS5ProxyPort = 1080
+	defaultHTTPProxyPort   = 8080
+)
+
+// dialThroughProxy opens a TCP connection to the target address, either directly or
+// through the given SOCKS5 or HTTP CONNECT proxy
+func dialThroughProxy(config *SSHProxyConfig, target string) (net.Conn, error) {
+	if config == nil {
+		return net.Dial("tcp", target)
+	}
+
+	address := config.address()
+	switch config.Type {
+	case proxyTypeSOCKS5:
+		var auth *proxy.Auth
+		if config.Username != nil || config.Password != nil {
+			auth = &proxy.Auth{}
+			if config.Username != nil {
+				auth.User = *config.Username
+			}
+			if config.Password != nil {
+				auth.Password = *config.Password
+			}
+		}
+		dialer, err := proxy.SOCKS5("tcp", address, auth, proxy.Direct)
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure SOCKS5 proxy %s: %w", address, err)
+		}
+		conn, err := dialer.Dial("tcp", target)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect through SOCKS5 proxy %s: %w", address, err)
+		}
+		return conn, nil
+	case proxyTypeHTTP:
+		conn, err := dialHTTPConnect(config, address, target)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect through HTTP proxy %s: %w", address, err)
+		}
+		return conn, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy type %q", config.Type)
+	}
+}
+
+// address returns the host:port of the proxy, using the default port of its type
+func (c *SSHProxyConfig) address() string {
+	port := int64(defaultSOCKS5ProxyPort)
+	if c.Type == proxyTypeHTTP {
+		port = defaultHTTPProxyPort
+	}
+	if c.Port != nil {
+		port = *c.Port
+	}
+	return net.JoinHostPort(c.Host, strconv.FormatInt(port, 10))
+}
+
+// dialHTTPConnect opens a tunnel to the target through an HTTP proxy using the CONNECT method
+func dialHTTPConnect(config *SSHProxyConfig, address string, target string) (net.Conn, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if config.Username != nil || config.Password != nil {
+		var username, password string
+		if config.Username != nil {
+			username = *config.Username
+		}
+		if config.Password != nil {
+			password = *config.Password
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The SSH server speaks first, so nothing but the response is buffered by the reader
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused CONNECT to %s: %s", target, resp.Status)
+	}
+
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// bufferedConn is a net.Conn that reads through a buffered reader
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// proxyFromEnvironment returns the proxy configured by the ALL_PROXY environment variable
+// for the target address, or nil when it is unset or the target matches NO_PROXY
+func proxyFromEnvironment(target string) (*SSHProxyConfig, error) {
+	allProxy := getEnv("ALL_PROXY", "all_proxy")
+	if allProxy == "" {
+		return nil, nil
+	}
+
+	// Reuse the NO_PROXY matching of the standard library by treating the target as an
+	// HTTPS endpoint whose proxy is ALL_PROXY
+	proxyFunc := (&httpproxy.Config{
+		HTTPSProxy: allProxy,
+		NoProxy:    getEnv("NO_PROXY", "no_proxy"),
+	}).ProxyFunc()
+	proxyURL, err := proxyFunc(&url.URL{Scheme: "https", Host: target})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ALL_PROXY %q: %w", allProxy, err)
+	}
+	if proxyURL == nil {
+		return nil, nil
+	}
+
+	config := &SSHProxyConfig{Host: proxyURL.Hostname()}
+	switch strings.ToLower(proxyURL.Scheme) {
+	case "socks5", "socks5h":
+		config.Type = proxyTypeSOCKS5
+	case "http":
+		config.Type = proxyTypeHTTP
+	default:
+		return nil, fmt.Errorf("unsupported ALL_PROXY scheme %q, expected socks5, socks5h or http", proxyURL.Scheme)
+	}
+	if value := proxyURL.Port(); value != "" {
+		port, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ALL_PROXY port %q: %w", value, err)
+		}
+		config.Port = &port
+	}
+	if proxyURL.User != nil {
+		username := proxyURL.User.Username()
+		config.Username = &username
+		if password, ok := proxyURL.User.Password(); ok {
+			config.Password = &password
+		}
+	}
+
+	return config, nil
+}
+
+// getEnv returns the value of the first of the given environment variables that is set
+func getEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
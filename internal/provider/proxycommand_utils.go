@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyCommandStderrLimit is the amount of stderr output of a proxy command kept for diagnostics
+const proxyCommandStderrLimit = 4096
+
+// expandProxyCommand replaces the %h, %p, %r and %% tokens of a proxy command
+func expandProxyCommand(command string, host string, port int64, user string) string {
+	var b strings.Builder
+	for i := 0; i < len(command); i++ {
+		if command[i] != '%' || i+1 == len(command) {
+			b.WriteByte(command[i])
+			continue
+		}
+		i++
+		switch command[i] {
+		case 'h':
+			b.WriteString(host)
+		case 'p':
+			b.WriteString(strconv.FormatInt(port, 10))
+		case 'r':
+			b.WriteString(user)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(command[i])
+		}
+	}
+	return b.String()
+}
+
+// proxyCommandConn is a net.Conn carried over the stdin and stdout of a local command.
+// Closing the connection terminates the command.
+type proxyCommandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr *tailBuffer
+	addr   proxyCommandAddr
+
+	closeOnce sync.Once
+}
+
+// dialProxyCommand starts the command through the local shell and returns a connection
+// over its stdin and stdout
+func dialProxyCommand(command string, target string) (*proxyCommandConn, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", command)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create stdin pipe for proxy command: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create stdout pipe for proxy command: %w", err)
+	}
+	stderr := &tailBuffer{limit: proxyCommandStderrLimit}
+	cmd.Stderr = stderr
+
+	// Do not wait forever for descendants of the command holding on to its stderr
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start proxy command %q: %w", command, err)
+	}
+
+	return &proxyCommandConn{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		addr:   proxyCommandAddr(target),
+	}, nil
+}
+
+func (c *proxyCommandConn) Read(b []byte) (int, error)  { return c.stdout.Read(b) }
+func (c *proxyCommandConn) Write(b []byte) (int, error) { return c.stdin.Write(b) }
+func (c *proxyCommandConn) LocalAddr() net.Addr         { return proxyCommandAddr("proxy-command") }
+func (c *proxyCommandConn) RemoteAddr() net.Addr        { return c.addr }
+
+// Deadlines are not supported by pipes to a child process
+func (c *proxyCommandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *proxyCommandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *proxyCommandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// Close closes the pipes, kills the command if it is still running and reaps it
+func (c *proxyCommandConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.stdin.Close()
+		if c.cmd.Process != nil {
+			c.cmd.Process.Kill()
+		}
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+// annotateError adds the stderr output of the command to an error of the connection,
+// unless the connection was established and the server rejected the credentials
+func (c *proxyCommandConn) annotateError(err error) error {
+	if strings.Contains(err.Error(), "unable to authenticate") {
+		return err
+	}
+	if stderr := strings.TrimSpace(c.stderr.String()); stderr != "" {
+		return fmt.Errorf("%w (proxy command stderr: %s)", err, stderr)
+	}
+	return err
+}
+
+// proxyCommandAddr is the address of a connection through a proxy command
+type proxyCommandAddr string
+
+func (a proxyCommandAddr) Network() string { return "proxy-command" }
+func (a proxyCommandAddr) String() string  { return string(a) }
+
+// tailBuffer is a concurrency safe writer keeping only the last limit bytes written
+type tailBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Write(p)
+	if overflow := b.buf.Len() - b.limit; overflow > 0 {
+		b.buf.Next(overflow)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,26 @@
+package provider
+
+import "testing"
+
+func TestExpandProxyCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    string
+	}{
+		{name: "no tokens", command: "nc bastion 22", want: "nc bastion 22"},
+		{name: "host and port", command: "nc %h %p", want: "nc db.internal 2222"},
+		{name: "user", command: "ssh -W %h:%p %r@bastion", want: "ssh -W db.internal:2222 deploy@bastion"},
+		{name: "escaped percent", command: "echo 100%% %%h", want: "echo 100% %h"},
+		{name: "unknown token is kept", command: "nc %x %h", want: "nc %x db.internal"},
+		{name: "trailing percent is kept", command: "nc %h %", want: "nc db.internal %"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandProxyCommand(tt.command, "db.internal", 2222, "deploy"); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/crypto/ssh"
+)
+
+// Default delays between connection attempts
+const (
+	defaultConnectRetryInterval    = time.Second
+	defaultConnectRetryMaxInterval = 30 * time.Second
+)
+
+// connectPolicy holds the timeout and retry settings of a connection
+type connectPolicy struct {
+	timeout          time.Duration
+	retries          int64 // negative for unlimited retries
+	retryTimeout     time.Duration
+	retryInterval    time.Duration
+	retryMaxInterval time.Duration
+}
+
+// newConnectPolicy parses the timeout and retry settings of a connection
+func newConnectPolicy(config SSHConnectionConfig) (connectPolicy, error) {
+	policy := connectPolicy{
+		retryInterval:    defaultConnectRetryInterval,
+		retryMaxInterval: defaultConnectRetryMaxInterval,
+	}
+
+	durations := []struct {
+		name  string
+		value *string
+		dest  *time.Duration
+	}{
+		{"connect_timeout", config.ConnectTimeout, &policy.timeout},
+		{"connect_retry_timeout", config.ConnectRetryTimeout, &policy.retryTimeout},
+		{"connect_retry_interval", config.ConnectRetryInterval, &policy.retryInterval},
+		{"connect_retry_max_interval", config.ConnectRetryMaxInterval, &policy.retryMaxInterval},
+	}
+	for _, d := range durations {
+		if d.value == nil {
+			continue
+		}
+		duration, err := time.ParseDuration(*d.value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid %s %q: %w", d.name, *d.value, err)
+		}
+		*d.dest = duration
+	}
+
+	// Without an explicit number of retries, a retry timeout retries until it expires
+	switch {
+	case config.ConnectRetries != nil:
+		policy.retries = *config.ConnectRetries
+	case config.ConnectRetryTimeout != nil:
+		policy.retries = -1
+	}
+
+	return policy, nil
+}
+
+// retry calls connect until it succeeds, fails with an error that is not transient, or
+// the retries are exhausted. The delay between attempts grows exponentially.
+func (p connectPolicy) retry(ctx context.Context, target string, connect func() (*ssh.Client, error)) (*ssh.Client, error) {
+	var deadline time.Time
+	if p.retryTimeout > 0 {
+		deadline = time.Now().Add(p.retryTimeout)
+	}
+	interval := p.retryInterval
+
+	for attempt := int64(1); ; attempt++ {
+		client, err := connect()
+		if err == nil {
+			return client, nil
+		}
+		if !isTransientError(err) || p.retries == 0 {
+			return nil, err
+		}
+		if (p.retries > 0 && attempt > p.retries) || (!deadline.IsZero() && time.Now().Add(interval).After(deadline)) {
+			return nil, fmt.Errorf("%w (gave up after %d attempts)", err, attempt)
+		}
+
+		tflog.Warn(ctx, fmt.Sprintf("Connection attempt %d to %s failed, retrying in %s: %s", attempt, target, interval, err))
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (retrying canceled: %w)", err, ctx.Err())
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > p.retryMaxInterval {
+			interval = p.retryMaxInterval
+		}
+	}
+}
+
+// isTransientError reports whether a connection error is likely to go away on its own,
+// such as a refused connection or a handshake cut short while the host is booting.
+// Authentication and host key verification failures are never transient.
+func isTransientError(err error) bool {
+	message := err.Error()
+	if strings.Contains(message, "unable to authenticate") || strings.Contains(message, "host key verification failed") {
+		return false
+	}
+
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var openErr *ssh.OpenChannelError
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, errConnectTimeout):
+		return true
+	case errors.As(err, &dnsErr):
+		return dnsErr.IsTemporary || dnsErr.IsNotFound || dnsErr.IsTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	case errors.As(err, &openErr):
+		// The bastion could not reach the next hop
+		return openErr.Reason == ssh.ConnectionFailed
+	}
+
+	// Errors of the handshake are not always wrapped, so fall back to their messages
+	return strings.HasSuffix(message, "EOF") || strings.Contains(message, "connection reset by peer")
+}
+
+// errConnectTimeout is returned when a connection is not established within connect_timeout
+var errConnectTimeout = errors.New("connection timed out")
+
+// withTimeout runs fn, giving up after the timeout. When it gives up, abort is called once
+// fn returns to release the resources it acquired. A zero timeout waits indefinitely.
+func withTimeout[T any](timeout time.Duration, fn func() (T, error), abort func(T)) (T, error) {
+	if timeout <= 0 {
+		return fn()
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-time.After(timeout):
+		go func() {
+			if r := <-done; r.err == nil {
+				abort(r.value)
+			}
+		}()
+		var zero T
+		return zero, fmt.Errorf("%w after %s", errConnectTimeout, timeout)
+	}
+}
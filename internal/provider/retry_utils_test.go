@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: true},
+		{name: "connection reset", err: fmt.Errorf("ssh: handshake failed: %w", syscall.ECONNRESET), want: true},
+		{name: "host unreachable", err: fmt.Errorf("dial: %w", syscall.EHOSTUNREACH), want: true},
+		{name: "handshake cut short", err: fmt.Errorf("ssh: handshake failed: %w", io.EOF), want: true},
+		{name: "unwrapped handshake EOF", err: errors.New("ssh: handshake failed: EOF"), want: true},
+		{name: "connect timeout", err: fmt.Errorf("failed to connect: %w", errConnectTimeout), want: true},
+		{name: "network timeout", err: &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, want: true},
+		{name: "dns not found", err: &net.DNSError{Err: "no such host", Name: "booting.example.com", IsNotFound: true}, want: true},
+		{name: "dns failure", err: &net.DNSError{Err: "server misbehaving", Name: "example.com"}, want: false},
+		{name: "next hop unreachable", err: &ssh.OpenChannelError{Reason: ssh.ConnectionFailed}, want: true},
+		{name: "forwarding prohibited", err: &ssh.OpenChannelError{Reason: ssh.Prohibited}, want: false},
+		{name: "authentication failure", err: fmt.Errorf("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password], no supported methods remain: %w", io.EOF), want: false},
+		{name: "host key mismatch", err: errors.New("host key verification failed: key does not match known key"), want: false},
+		{name: "other error", err: errors.New("unable to parse private key"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientError(tt.err); got != tt.want {
+				t.Errorf("isTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
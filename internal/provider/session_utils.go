@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultMaxSessionsPerHost matches the default MaxSessions of OpenSSH's sshd
+const defaultMaxSessionsPerHost = 10
+
+// sessionLimiter bounds the number of sessions open at the same time, both per
+// connection and across all connections
+type sessionLimiter struct {
+	maxPerHost int64
+	global     chan struct{}
+
+	mu      sync.Mutex
+	perHost map[*ssh.Client]chan struct{}
+}
+
+// newSessionLimiter creates a limiter, where a limit of zero means unlimited
+func newSessionLimiter(maxPerHost int64, maxTotal int64) *sessionLimiter {
+	limiter := &sessionLimiter{
+		maxPerHost: maxPerHost,
+		perHost:    make(map[*ssh.Client]chan struct{}),
+	}
+	if maxTotal > 0 {
+		limiter.global = make(chan struct{}, maxTotal)
+	}
+	return limiter
+}
+
+// hostSemaphore returns the semaphore of the client, which is dropped once it is closed
+func (l *sessionLimiter) hostSemaphore(client *ssh.Client) chan struct{} {
+	if l.maxPerHost <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	semaphore, ok := l.perHost[client]
+	if !ok {
+		semaphore = make(chan struct{}, l.maxPerHost)
+		l.perHost[client] = semaphore
+		go func() {
+			client.Wait()
+			l.mu.Lock()
+			delete(l.perHost, client)
+			l.mu.Unlock()
+		}()
+	}
+	return semaphore
+}
+
+// acquire waits for a free session slot on the client and returns the function releasing it
+func (l *sessionLimiter) acquire(ctx context.Context, client *ssh.Client) (func(), error) {
+	var acquired []chan struct{}
+	release := func() {
+		for _, semaphore := range acquired {
+			<-semaphore
+		}
+	}
+
+	host := client.RemoteAddr().String()
+	for _, s := range []struct {
+		semaphore chan struct{}
+		scope     string
+	}{
+		{l.hostSemaphore(client), "on " + host},
+		{l.global, "across all hosts"},
+	} {
+		if s.semaphore == nil {
+			continue
+		}
+
+		// Only log when the slot is not immediately available
+		select {
+		case s.semaphore <- struct{}{}:
+			acquired = append(acquired, s.semaphore)
+			continue
+		default:
+		}
+
+		start := time.Now()
+		tflog.Debug(ctx, fmt.Sprintf("All %d SSH sessions %s are in use, waiting for a free session", cap(s.semaphore), s.scope))
+		select {
+		case s.semaphore <- struct{}{}:
+			acquired = append(acquired, s.semaphore)
+			tflog.Debug(ctx, fmt.Sprintf("Acquired SSH session %s after waiting %s", s.scope, time.Since(start).Round(time.Millisecond)))
+		case <-ctx.Done():
+			release()
+			return nil, fmt.Errorf("canceled while waiting for a free SSH session %s: %w", s.scope, ctx.Err())
+		}
+	}
+
+	return release, nil
+}
+
+// NewSession opens a session on the client once a session slot is available. The
+// returned function closes the session and releases its slot.
+func (m *SSHManager) NewSession(ctx context.Context, client *ssh.Client) (*ssh.Session, func(), error) {
+	return openSession(ctx, m, client, func(client *ssh.Client) (*ssh.Session, error) {
+		session, err := client.NewSession()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+		return session, nil
+	})
+}
+
+// NewSFTPClient opens an SFTP client on the client once a session slot is available,
+// since the SFTP subsystem runs in its own session. The returned function closes the
+// SFTP client and releases its slot.
+func (m *SSHManager) NewSFTPClient(ctx context.Context, client *ssh.Client) (*sftp.Client, func(), error) {
+	return openSession(ctx, m, client, func(client *ssh.Client) (*sftp.Client, error) {
+		sftpClient, err := sftp.NewClient(client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SFTP client: %w", err)
+		}
+		return sftpClient, nil
+	})
+}
+
+// openSession opens a session with open once a session slot is available. When the
+// connection of the client turns out to be closed, it is reconnected and the session is
+// opened once more on the new client. The returned function closes the session and
+// releases its slot.
+func openSession[T io.Closer](ctx context.Context, m *SSHManager, client *ssh.Client, open func(*ssh.Client) (T, error)) (T, func(), error) {
+	var release func()
+	session, client, err := withReconnect(ctx, m, client, func(client *ssh.Client) (T, error) {
+		var err error
+		release, err = m.sessions.acquire(ctx, client)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		session, err := open(client)
+		if err != nil {
+			release()
+		}
+		return session, err
+	})
+	if err != nil {
+		return session, nil, err
+	}
+	untrack := m.trackSession(client)
+
+	return session, func() {
+		session.Close()
+		untrack()
+		release()
+	}, nil
+}
@@ -5,45 +5,124 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 type SSHExecDataSourceModel struct {
 	Command       types.String `tfsdk:"command"`
 	Output        types.String `tfsdk:"output"`
+	Stdout        types.String `tfsdk:"stdout"`
+	Stderr        types.String `tfsdk:"stderr"`
 	ExitCode      types.Int64  `tfsdk:"exit_code"`
 	FailIfNonzero types.Bool   `tfsdk:"fail_if_nonzero"`
 	Id            types.String `tfsdk:"id"`
 
+	Stdin                types.String            `tfsdk:"stdin"`
+	StdinBase64          types.String            `tfsdk:"stdin_base64"`
+	StdinSHA256          types.String            `tfsdk:"stdin_sha256"`
+	Environment          types.Map               `tfsdk:"environment"`
+	SensitiveEnvironment types.Map               `tfsdk:"sensitive_environment"`
+	WorkingDir           types.String            `tfsdk:"working_dir"`
+	RemoteForwards       []SSHRemoteForwardModel `tfsdk:"remote_forwards"`
+
+	Timeout                types.String `tfsdk:"timeout"`
+	TerminationSignals     types.List   `tfsdk:"termination_signals"`
+	TerminationGracePeriod types.String `tfsdk:"termination_grace_period"`
+
+	// Privilege escalation
+	SSHBecomeModel
+
 	// Connection details
 	SSHConnectionModel
-	UseProviderAsBastion types.Bool          `tfsdk:"use_provider_as_bastion"`
-	Bastion              *SSHConnectionModel `tfsdk:"bastion"`
+	UseProviderAsBastion types.Bool           `tfsdk:"use_provider_as_bastion"`
+	ConnectionName       types.String         `tfsdk:"connection_name"`
+	Bastion              *SSHConnectionModel  `tfsdk:"bastion"`
+	JumpHosts            []SSHConnectionModel `tfsdk:"jump_hosts"`
 }
 
 var SSHExecDataSourceSchema = schema.Schema{
 	Description: "Execute commands over SSH",
 	Attributes: map[string]schema.Attribute{
 		"command":         schema.StringAttribute{Required: true, Description: "Command to execute"},
-		"output":          schema.StringAttribute{Computed: true, Description: "Output of the command"},
+		"output":          schema.StringAttribute{Computed: true, Description: "Combined stdout and stderr of the command, in the order they were written"},
+		"stdout":          schema.StringAttribute{Computed: true, Description: "Standard output of the command"},
+		"stderr":          schema.StringAttribute{Computed: true, Description: "Standard error of the command"},
 		"exit_code":       schema.Int64Attribute{Computed: true, Description: "Exit code of the command"},
 		"fail_if_nonzero": schema.BoolAttribute{Optional: true, Description: "Whether to fail if the command returns a non-zero exit code"},
 		"id":              schema.StringAttribute{Computed: true, Description: "Unique identifier for this execution"},
+		"stdin": schema.StringAttribute{
+			Optional:    true,
+			Sensitive:   true,
+			Description: "Data written to the standard input of the command, keeping secrets and scripts out of the command line. It is not stored in state, only its hash in stdin_sha256",
+			Validators:  []validator.String{stringvalidator.ConflictsWith(path.MatchRoot("stdin_base64"))},
+		},
+		"stdin_base64": schema.StringAttribute{
+			Optional:    true,
+			Sensitive:   true,
+			Description: "Base64-encoded binary data written to the standard input of the command. Like stdin, it is not stored in state",
+		},
+		"stdin_sha256":          schema.StringAttribute{Computed: true, Description: "SHA-256 hash of the standard input of the command"},
+		"environment":           environmentAttribute,
+		"sensitive_environment": sensitiveEnvironmentAttribute,
+		"working_dir":           workingDirAttribute,
+		"remote_forwards":       remoteForwardsAttribute,
+
+		// Termination of commands that time out or are canceled
+		"timeout":                  timeoutAttribute,
+		"termination_signals":      terminationSignalsAttribute,
+		"termination_grace_period": terminationGracePeriodAttribute,
+
+		// Privilege escalation
+		"become":          SSHBecomeSchema.Become,
+		"become_user":     SSHBecomeSchema.BecomeUser,
+		"become_method":   SSHBecomeSchema.BecomeMethod,
+		"become_password": SSHBecomeSchema.BecomePassword,
 
 		// Common SSH connection attributes
-		"host":                    SSHConnectionSchema.Host,
-		"user":                    SSHConnectionSchema.User,
-		"password":                SSHConnectionSchema.Password,
-		"private_key":             SSHConnectionSchema.PrivateKey,
-		"port":                    SSHConnectionSchema.Port,
-		"use_provider_as_bastion": SSHConnectionSchema.UseProviderAsBastion,
-		"bastion":                 SSHConnectionSchema.Bastion,
+		"host":                         SSHConnectionSchema.Host,
+		"user":                         SSHConnectionSchema.User,
+		"password":                     SSHConnectionSchema.Password,
+		"private_key":                  SSHConnectionSchema.PrivateKey,
+		"private_key_passphrase":       SSHConnectionSchema.PrivateKeyPassphrase,
+		"private_key_path":             SSHConnectionSchema.PrivateKeyPath,
+		"password_file":                SSHConnectionSchema.PasswordFile,
+		"keyboard_interactive_answers": SSHConnectionSchema.KeyboardInteractiveAnswers,
+		"totp_secret":                  SSHConnectionSchema.TOTPSecret,
+		"certificate":                  SSHConnectionSchema.Certificate,
+		"port":                         SSHConnectionSchema.Port,
+		"proxy_command":                SSHConnectionSchema.ProxyCommand,
+		"proxy":                        SSHConnectionSchema.Proxy,
+		"agent":                        SSHConnectionSchema.Agent,
+		"agent_socket":                 SSHConnectionSchema.AgentSocket,
+		"agent_identity":               SSHConnectionSchema.AgentIdentity,
+		"known_hosts_files":            SSHConnectionSchema.KnownHostsFiles,
+		"host_keys":                    SSHConnectionSchema.HostKeys,
+		"strict_host_key_checking":     SSHConnectionSchema.StrictHostKeyChecking,
+		"connect_timeout":              SSHConnectionSchema.ConnectTimeout,
+		"connect_retries":              SSHConnectionSchema.ConnectRetries,
+		"connect_retry_timeout":        SSHConnectionSchema.ConnectRetryTimeout,
+		"connect_retry_interval":       SSHConnectionSchema.ConnectRetryInterval,
+		"connect_retry_max_interval":   SSHConnectionSchema.ConnectRetryMaxInterval,
+		"keepalive_interval":           SSHConnectionSchema.KeepaliveInterval,
+		"keepalive_count_max":          SSHConnectionSchema.KeepaliveCountMax,
+		"ciphers":                      SSHConnectionSchema.Ciphers,
+		"key_exchanges":                SSHConnectionSchema.KeyExchanges,
+		"macs":                         SSHConnectionSchema.MACs,
+		"host_key_algorithms":          SSHConnectionSchema.HostKeyAlgorithms,
+		"use_provider_as_bastion":      SSHConnectionSchema.UseProviderAsBastion,
+		"connection_name":              SSHConnectionSchema.ConnectionName,
+		"bastion":                      SSHConnectionSchema.Bastion,
+		"jump_hosts":                   SSHConnectionSchema.JumpHosts,
 	},
 }
 
 var _ datasource.DataSource = &SSHExecDataSource{}
+var _ datasource.DataSourceWithValidateConfig = &SSHExecDataSource{}
 
 func NewSSHExecDataSource() datasource.DataSource {
 	return &SSHExecDataSource{}
@@ -53,6 +132,20 @@ type SSHExecDataSource struct {
 	manager *SSHManager
 }
 
+// execOptions returns how to run the commands of the model
+func (m *SSHExecDataSourceModel) execOptions(manager *SSHManager, stdin []byte) execOptions {
+	options := execOptions{
+		FailIfNonzero:  m.FailIfNonzero.ValueBool(),
+		RemoteForwards: remoteForwardConfigs(m.RemoteForwards),
+		Stdin:          stdin,
+		Environment:    commandEnvironment(m.Environment, m.SensitiveEnvironment),
+		WorkingDir:     m.WorkingDir.ValueString(),
+		Become:         manager.resolveBecome(m.ConnectionName, &m.SSHBecomeModel),
+	}
+	terminationOptions(&options, m.Timeout, m.TerminationSignals, m.TerminationGracePeriod)
+	return options
+}
+
 func (d *SSHExecDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_exec"
 }
@@ -78,6 +171,24 @@ func (d *SSHExecDataSource) Configure(_ context.Context, req datasource.Configur
 	d.manager = manager
 }
 
+func (d *SSHExecDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var data SSHExecDataSourceModel
+
+	// Nested objects that are not known yet are validated once they are
+	if diags := req.Config.Get(ctx, &data); diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(validateEnvironment(data.Environment, data.SensitiveEnvironment)...)
+	resp.Diagnostics.Append(validateConnection(d.manager, data.ConnectionName, &data.SSHConnectionModel, data.UseProviderAsBastion, data.Bastion, data.JumpHosts)...)
+
+	if !data.StdinBase64.IsUnknown() {
+		if _, err := stdinBytes(types.StringNull(), data.StdinBase64); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("stdin_base64"), "Invalid stdin_base64", err.Error())
+		}
+	}
+}
+
 func (d *SSHExecDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
 	var data SSHExecDataSourceModel
 
@@ -94,11 +205,18 @@ func (d *SSHExecDataSource) Read(ctx context.Context, req datasource.ReadRequest
 	// Generate ID early, based on the command
 	data.Id = types.StringValue(generateExecID(data.Command.ValueString(), time.Now()))
 
+	stdin, err := stdinBytes(data.Stdin, data.StdinBase64)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("stdin_base64"), "Invalid stdin_base64", err.Error())
+		return
+	}
+
 	// Get SSH client
 	client, err := d.manager.GetClient(
-		*data.SSHConnectionModel.toConfig(),
+		ctx,
+		connectionConfig(data.ConnectionName, &data.SSHConnectionModel),
 		data.UseProviderAsBastion.ValueBool(),
-		data.Bastion.toConfig(),
+		jumpHostConfigs(data.Bastion, data.JumpHosts),
 		nil,
 	)
 	if err != nil {
@@ -107,18 +225,27 @@ func (d *SSHExecDataSource) Read(ctx context.Context, req datasource.ReadRequest
 	}
 
 	// Execute the command
-	output, exitCode, err := executeCommand(
+	result, err := executeCommand(
+		ctx,
+		d.manager,
 		client,
 		data.Command.ValueString(),
-		data.FailIfNonzero.ValueBool(),
+		data.execOptions(d.manager, stdin),
 	)
 	if err != nil {
-		resp.Diagnostics.AddError("Command execution failed", err.Error())
+		resp.Diagnostics.AddError(execErrorSummary(err, "Command execution failed"), err.Error())
 		return
 	}
 
-	data.Output = types.StringValue(output)
-	data.ExitCode = types.Int64Value(exitCode)
+	data.Output = types.StringValue(result.Output)
+	data.Stdout = types.StringValue(result.Stdout)
+	data.Stderr = types.StringValue(result.Stderr)
+	data.ExitCode = types.Int64Value(result.ExitCode)
+
+	// Only the hash of the standard input is stored in state
+	data.Stdin = types.StringNull()
+	data.StdinBase64 = types.StringNull()
+	data.StdinSHA256 = stdinSHA256(stdin)
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
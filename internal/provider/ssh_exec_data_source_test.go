@@ -31,6 +31,19 @@ func TestAccSSHExecDataSource(t *testing.T) {
 					resource.TestCheckResourceAttr("data.ssh_exec.nonzero_allowed", "exit_code", "1"),
 					resource.TestCheckResourceAttr("data.ssh_exec.nonzero_allowed", "output", ""),
 
+					// Separate stdout and stderr
+					resource.TestCheckResourceAttr("data.ssh_exec.streams", "stdout", "data\n"),
+					resource.TestCheckResourceAttr("data.ssh_exec.streams", "stderr", "warning\n"),
+					resource.TestCheckResourceAttr("data.ssh_exec.streams", "output", "data\nwarning\n"),
+
+					// Binary standard input
+					resource.TestCheckResourceAttr("data.ssh_exec.stdin", "stdout", "hello"),
+					resource.TestCheckNoResourceAttr("data.ssh_exec.stdin", "stdin_base64"),
+					resource.TestCheckResourceAttr("data.ssh_exec.stdin", "stdin_sha256", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"),
+
+					// Environment variables and working directory
+					resource.TestCheckResourceAttr("data.ssh_exec.environment", "stdout", "it's me\n/tmp\ns3cr3t\n"),
+
 					// Multiline command
 					resource.TestCheckResourceAttr("data.ssh_exec.multiline", "exit_code", "0"),
 					resource.TestCheckResourceAttr("data.ssh_exec.multiline", "output", "Line 1\nLine 2\n"),
@@ -69,6 +82,26 @@ data "ssh_exec" "nonzero_allowed" {
   fail_if_nonzero = false
 }
 
+data "ssh_exec" "streams" {
+  command = "echo data; sleep 1; echo warning >&2"
+}
+
+data "ssh_exec" "stdin" {
+  command      = "cat"
+  stdin_base64 = base64encode("hello")
+}
+
+data "ssh_exec" "environment" {
+  command     = "echo \"$GREETING\"; pwd; echo \"$TOKEN\""
+  working_dir = "/tmp"
+  environment = {
+    GREETING = "it's me"
+  }
+  sensitive_environment = {
+    TOKEN = "s3cr3t"
+  }
+}
+
 data "ssh_exec" "multiline" {
   command = <<-EOF
 	  echo "Line 1"
@@ -168,3 +201,64 @@ data "ssh_exec" "whoami" {
 		getEnvVarOrSkip(t, "SSH_USER"),
 		getEnvVarOrSkip(t, "SSH_PRIVATE_KEY_PATH"))
 }
+
+// Test for expected failure when the host does not present a pinned host key
+func TestAccSSHExecDataSource_HostKeyMismatch(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccSSHExecDataSourceConfigHostKeyMismatch(t),
+				ExpectError: regexp.MustCompile(`does not match any pinned host key`),
+			},
+		},
+	})
+}
+
+func testAccSSHExecDataSourceConfigHostKeyMismatch(t *testing.T) string {
+	return fmt.Sprintf(`
+provider "ssh" {
+  host      = "%s"
+  user      = "%s"
+  password  = "%s"
+  host_keys = ["SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"]
+}
+
+data "ssh_exec" "pinned" {
+  command = "echo hi"
+}
+`, getEnvVarOrSkip(t, "SSH_HOST"), getEnvVarOrSkip(t, "SSH_USER"), getEnvVarOrSkip(t, "SSH_PASSWORD"))
+}
+
+// Test for expected failure when the command runs longer than its timeout
+func TestAccSSHExecDataSource_Timeout(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccSSHExecDataSourceConfigTimeout(t),
+				ExpectError: regexp.MustCompile(`(?s)command timed out after 2s.*Partial output: started`),
+			},
+		},
+	})
+}
+
+func testAccSSHExecDataSourceConfigTimeout(t *testing.T) string {
+	return fmt.Sprintf(`
+provider "ssh" {
+  host     = "%s"
+  user     = "%s"
+  password = "%s"
+}
+
+data "ssh_exec" "hanging" {
+  command = "echo started; sleep 60"
+  timeout = "2s"
+
+  termination_signals      = ["INT", "KILL"]
+  termination_grace_period = "1s"
+}
+`, getEnvVarOrSkip(t, "SSH_HOST"), getEnvVarOrSkip(t, "SSH_USER"), getEnvVarOrSkip(t, "SSH_PASSWORD"))
+}
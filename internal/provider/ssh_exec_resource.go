@@ -3,50 +3,213 @@ package provider
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/mapvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 type SSHExecResourceModel struct {
 	Command       types.String `tfsdk:"command"`
 	Output        types.String `tfsdk:"output"`
+	Stdout        types.String `tfsdk:"stdout"`
+	Stderr        types.String `tfsdk:"stderr"`
 	ExitCode      types.Int64  `tfsdk:"exit_code"`
 	FailIfNonzero types.Bool   `tfsdk:"fail_if_nonzero"`
 	OnDestroy     types.String `tfsdk:"on_destroy"`
 	Id            types.String `tfsdk:"id"`
 
+	Stdin                types.String            `tfsdk:"stdin"`
+	StdinBase64          types.String            `tfsdk:"stdin_base64"`
+	StdinSHA256          types.String            `tfsdk:"stdin_sha256"`
+	Environment          types.Map               `tfsdk:"environment"`
+	SensitiveEnvironment types.Map               `tfsdk:"sensitive_environment"`
+	WorkingDir           types.String            `tfsdk:"working_dir"`
+	RemoteForwards       []SSHRemoteForwardModel `tfsdk:"remote_forwards"`
+
+	Timeout                types.String `tfsdk:"timeout"`
+	TerminationSignals     types.List   `tfsdk:"termination_signals"`
+	TerminationGracePeriod types.String `tfsdk:"termination_grace_period"`
+
+	// Privilege escalation
+	SSHBecomeModel
+
 	// Connection details
 	SSHConnectionModel
-	UseProviderAsBastion types.Bool          `tfsdk:"use_provider_as_bastion"`
-	Bastion              *SSHConnectionModel `tfsdk:"bastion"`
+	UseProviderAsBastion types.Bool           `tfsdk:"use_provider_as_bastion"`
+	ConnectionName       types.String         `tfsdk:"connection_name"`
+	Bastion              *SSHConnectionModel  `tfsdk:"bastion"`
+	JumpHosts            []SSHConnectionModel `tfsdk:"jump_hosts"`
 }
 
 var SSHExecResourceSchema = schema.Schema{
 	Description: "Execute commands over SSH with potential side effects",
 	Attributes: map[string]schema.Attribute{
 		"command":         schema.StringAttribute{Required: true, Description: "Command to execute"},
-		"output":          schema.StringAttribute{Computed: true, Description: "Output of the command"},
+		"output":          schema.StringAttribute{Computed: true, Description: "Combined stdout and stderr of the command, in the order they were written"},
+		"stdout":          schema.StringAttribute{Computed: true, Description: "Standard output of the command"},
+		"stderr":          schema.StringAttribute{Computed: true, Description: "Standard error of the command"},
 		"exit_code":       schema.Int64Attribute{Computed: true, Description: "Exit code of the command"},
 		"fail_if_nonzero": schema.BoolAttribute{Optional: true, Computed: true, Default: booldefault.StaticBool(true), Description: "Whether to fail if the command returns a non-zero exit code. Defaults to true if not specified."},
 		"on_destroy":      schema.StringAttribute{Optional: true, Description: "Command to execute when the resource is destroyed"},
 		"id":              schema.StringAttribute{Computed: true, Description: "Unique identifier for this execution"},
+		"stdin": schema.StringAttribute{
+			Optional:    true,
+			Sensitive:   true,
+			WriteOnly:   true,
+			Description: "Data written to the standard input of the command, keeping secrets and scripts out of the command line. Write-only: it is never stored in the plan or state, only its hash in stdin_sha256. Requires Terraform 1.11 or later",
+			Validators:  []validator.String{stringvalidator.ConflictsWith(path.MatchRoot("stdin_base64"))},
+		},
+		"stdin_base64": schema.StringAttribute{
+			Optional:    true,
+			Sensitive:   true,
+			WriteOnly:   true,
+			Description: "Base64-encoded binary data written to the standard input of the command. Write-only like stdin",
+		},
+		"stdin_sha256":          schema.StringAttribute{Computed: true, Description: "SHA-256 hash of the standard input of the command. The command is run again when it changes"},
+		"environment":           environmentAttribute,
+		"sensitive_environment": sensitiveEnvironmentAttribute,
+		"working_dir":           workingDirAttribute,
+		"remote_forwards":       remoteForwardsAttribute,
+
+		// Termination of commands that time out or are canceled
+		"timeout":                  timeoutAttribute,
+		"termination_signals":      terminationSignalsAttribute,
+		"termination_grace_period": terminationGracePeriodAttribute,
+
+		// Privilege escalation
+		"become":          SSHBecomeSchema.Become,
+		"become_user":     SSHBecomeSchema.BecomeUser,
+		"become_method":   SSHBecomeSchema.BecomeMethod,
+		"become_password": SSHBecomeSchema.BecomePassword,
 
 		// Common SSH connection attributes
-		"host":                    SSHConnectionSchema.Host,
-		"user":                    SSHConnectionSchema.User,
-		"password":                SSHConnectionSchema.Password,
-		"private_key":             SSHConnectionSchema.PrivateKey,
-		"port":                    SSHConnectionSchema.Port,
-		"use_provider_as_bastion": SSHConnectionSchema.UseProviderAsBastion,
-		"bastion":                 SSHConnectionSchema.Bastion,
+		"host":                         SSHConnectionSchema.Host,
+		"user":                         SSHConnectionSchema.User,
+		"password":                     SSHConnectionSchema.Password,
+		"private_key":                  SSHConnectionSchema.PrivateKey,
+		"private_key_passphrase":       SSHConnectionSchema.PrivateKeyPassphrase,
+		"private_key_path":             SSHConnectionSchema.PrivateKeyPath,
+		"password_file":                SSHConnectionSchema.PasswordFile,
+		"keyboard_interactive_answers": SSHConnectionSchema.KeyboardInteractiveAnswers,
+		"totp_secret":                  SSHConnectionSchema.TOTPSecret,
+		"certificate":                  SSHConnectionSchema.Certificate,
+		"port":                         SSHConnectionSchema.Port,
+		"proxy_command":                SSHConnectionSchema.ProxyCommand,
+		"proxy":                        SSHConnectionSchema.Proxy,
+		"agent":                        SSHConnectionSchema.Agent,
+		"agent_socket":                 SSHConnectionSchema.AgentSocket,
+		"agent_identity":               SSHConnectionSchema.AgentIdentity,
+		"known_hosts_files":            SSHConnectionSchema.KnownHostsFiles,
+		"host_keys":                    SSHConnectionSchema.HostKeys,
+		"strict_host_key_checking":     SSHConnectionSchema.StrictHostKeyChecking,
+		"connect_timeout":              SSHConnectionSchema.ConnectTimeout,
+		"connect_retries":              SSHConnectionSchema.ConnectRetries,
+		"connect_retry_timeout":        SSHConnectionSchema.ConnectRetryTimeout,
+		"connect_retry_interval":       SSHConnectionSchema.ConnectRetryInterval,
+		"connect_retry_max_interval":   SSHConnectionSchema.ConnectRetryMaxInterval,
+		"keepalive_interval":           SSHConnectionSchema.KeepaliveInterval,
+		"keepalive_count_max":          SSHConnectionSchema.KeepaliveCountMax,
+		"ciphers":                      SSHConnectionSchema.Ciphers,
+		"key_exchanges":                SSHConnectionSchema.KeyExchanges,
+		"macs":                         SSHConnectionSchema.MACs,
+		"host_key_algorithms":          SSHConnectionSchema.HostKeyAlgorithms,
+		"use_provider_as_bastion":      SSHConnectionSchema.UseProviderAsBastion,
+		"connection_name":              SSHConnectionSchema.ConnectionName,
+		"bastion":                      SSHConnectionSchema.Bastion,
+		"jump_hosts":                   SSHConnectionSchema.JumpHosts,
+	},
+}
+
+// SSHRemoteForwardModel is a port of the SSH server forwarded back to the machine
+// running Terraform while a command runs
+type SSHRemoteForwardModel struct {
+	RemoteHost types.String `tfsdk:"remote_host"`
+	RemotePort types.Int64  `tfsdk:"remote_port"`
+	LocalHost  types.String `tfsdk:"local_host"`
+	LocalPort  types.Int64  `tfsdk:"local_port"`
+}
+
+// remoteForwardsAttribute is shared by the ssh_exec resource and data source, like the
+// other command attributes below
+var remoteForwardsAttribute = schema.ListNestedAttribute{
+	Description: "Ports the SSH server listens on while the command runs, forwarding connections back to addresses reachable from the machine running Terraform (e.g. a local artifact server)",
+	Optional:    true,
+	NestedObject: schema.NestedAttributeObject{
+		Attributes: map[string]schema.Attribute{
+			"remote_host": schema.StringAttribute{Description: "Address the SSH server listens on. Defaults to '127.0.0.1'; other addresses require GatewayPorts on the server", Optional: true},
+			"remote_port": schema.Int64Attribute{Description: "Port the SSH server listens on", Required: true, Validators: portValidators},
+			"local_host":  schema.StringAttribute{Description: "Host to forward connections to, resolved from the machine running Terraform. Defaults to '127.0.0.1'", Optional: true},
+			"local_port":  schema.Int64Attribute{Description: "Port to forward connections to", Required: true, Validators: portValidators},
+		},
+	},
+}
+
+// envNameRegex matches the environment variable names accepted by POSIX shells
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var environmentAttribute = schema.MapAttribute{
+	Description: "Environment variables of the command. They are sent to the server, and exported at the start of the command when the server does not accept them (see AcceptEnv in sshd_config). Use sensitive_environment for secrets",
+	Optional:    true,
+	ElementType: types.StringType,
+	Validators: []validator.Map{
+		mapvalidator.KeysAre(stringvalidator.RegexMatches(envNameRegex, "must be a valid environment variable name")),
+	},
+}
+
+var sensitiveEnvironmentAttribute = schema.MapAttribute{
+	Description: "Environment variables of the command like environment, whose values are hidden from the plan output. A variable cannot be set in both",
+	Optional:    true,
+	Sensitive:   true,
+	ElementType: types.StringType,
+	Validators: []validator.Map{
+		mapvalidator.KeysAre(stringvalidator.RegexMatches(envNameRegex, "must be a valid environment variable name")),
+	},
+}
+
+var workingDirAttribute = schema.StringAttribute{
+	Description: "Directory to run the command in. Relative paths are relative to the home directory of the user",
+	Optional:    true,
+}
+
+// supportedSignals are the signals defined by RFC 4254
+var supportedSignals = []string{"ABRT", "ALRM", "FPE", "HUP", "ILL", "INT", "KILL", "PIPE", "QUIT", "SEGV", "TERM", "USR1", "USR2"}
+
+var timeoutAttribute = schema.StringAttribute{
+	Description: "Maximum time the command may run (e.g. '10m'), after which it is terminated. Defaults to no timeout",
+	Optional:    true,
+	Validators:  durationValidators,
+}
+
+var terminationSignalsAttribute = schema.ListAttribute{
+	Description: "Signals sent in turn to a command that timed out or was canceled, before its session is closed. Defaults to [\"TERM\", \"KILL\"]",
+	Optional:    true,
+	ElementType: types.StringType,
+	Validators: []validator.List{
+		listvalidator.SizeAtLeast(1),
+		listvalidator.ValueStringsAre(stringvalidator.OneOf(supportedSignals...)),
 	},
 }
 
+var terminationGracePeriodAttribute = schema.StringAttribute{
+	Description: "Time to wait for the command to exit after each termination signal. Defaults to '10s'",
+	Optional:    true,
+	Validators:  durationValidators,
+}
+
 var _ resource.Resource = &SSHExecResource{}
+var _ resource.ResourceWithValidateConfig = &SSHExecResource{}
+var _ resource.ResourceWithModifyPlan = &SSHExecResource{}
 
 func NewSSHExecResource() resource.Resource {
 	return &SSHExecResource{}
@@ -56,6 +219,20 @@ type SSHExecResource struct {
 	manager *SSHManager
 }
 
+// execOptions returns how to run the commands of the model
+func (m *SSHExecResourceModel) execOptions(manager *SSHManager, stdin []byte) execOptions {
+	options := execOptions{
+		FailIfNonzero:  m.FailIfNonzero.ValueBool(),
+		RemoteForwards: remoteForwardConfigs(m.RemoteForwards),
+		Stdin:          stdin,
+		Environment:    commandEnvironment(m.Environment, m.SensitiveEnvironment),
+		WorkingDir:     m.WorkingDir.ValueString(),
+		Become:         manager.resolveBecome(m.ConnectionName, &m.SSHBecomeModel),
+	}
+	terminationOptions(&options, m.Timeout, m.TerminationSignals, m.TerminationGracePeriod)
+	return options
+}
+
 func (r *SSHExecResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_exec"
 }
@@ -81,6 +258,50 @@ func (r *SSHExecResource) Configure(_ context.Context, req resource.ConfigureReq
 	r.manager = manager
 }
 
+func (r *SSHExecResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data SSHExecResourceModel
+
+	// Nested objects that are not known yet are validated once they are
+	if diags := req.Config.Get(ctx, &data); diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(validateEnvironment(data.Environment, data.SensitiveEnvironment)...)
+	resp.Diagnostics.Append(validateConnection(r.manager, data.ConnectionName, &data.SSHConnectionModel, data.UseProviderAsBastion, data.Bastion, data.JumpHosts)...)
+
+	if !data.StdinBase64.IsUnknown() {
+		if _, err := stdinBytes(types.StringNull(), data.StdinBase64); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("stdin_base64"), "Invalid stdin_base64", err.Error())
+		}
+	}
+}
+
+// ModifyPlan records the hash of the standard input, which is not stored itself, so that
+// changing it runs the command again
+func (r *SSHExecResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing to plan when the resource is destroyed
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var config SSHExecResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	hash := types.StringUnknown()
+	if !config.Stdin.IsUnknown() && !config.StdinBase64.IsUnknown() {
+		stdin, err := stdinBytes(config.Stdin, config.StdinBase64)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("stdin_base64"), "Invalid stdin_base64", err.Error())
+			return
+		}
+		hash = stdinSHA256(stdin)
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("stdin_sha256"), hash)...)
+}
+
 func (r *SSHExecResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
 	var data SSHExecResourceModel
 
@@ -93,6 +314,12 @@ func (r *SSHExecResource) Create(ctx context.Context, req resource.CreateRequest
 	if data.Output.IsNull() {
 		data.Output = types.StringValue("")
 	}
+	if data.Stdout.IsNull() {
+		data.Stdout = types.StringValue("")
+	}
+	if data.Stderr.IsNull() {
+		data.Stderr = types.StringValue("")
+	}
 	if data.ExitCode.IsNull() {
 		data.ExitCode = types.Int64Value(0)
 	}
@@ -100,11 +327,18 @@ func (r *SSHExecResource) Create(ctx context.Context, req resource.CreateRequest
 	// Generate a unique, stable ID before executing the command
 	data.Id = types.StringValue(generateExecID(data.Command.ValueString(), time.Now()))
 
+	stdin, diags := configStdin(ctx, req.Config)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	// Get SSH client
 	client, err := r.manager.GetClient(
-		*data.SSHConnectionModel.toConfig(),
+		ctx,
+		connectionConfig(data.ConnectionName, &data.SSHConnectionModel),
 		data.UseProviderAsBastion.ValueBool(),
-		data.Bastion.toConfig(),
+		jumpHostConfigs(data.Bastion, data.JumpHosts),
 		nil,
 	)
 	if err != nil {
@@ -113,21 +347,41 @@ func (r *SSHExecResource) Create(ctx context.Context, req resource.CreateRequest
 	}
 
 	// Execute the command
-	output, exitCode, err := executeCommand(
+	result, err := executeCommand(
+		ctx,
+		r.manager,
 		client,
 		data.Command.ValueString(),
-		data.FailIfNonzero.ValueBool(),
+		data.execOptions(r.manager, stdin),
 	)
 	if err != nil {
-		resp.Diagnostics.AddError("Command execution failed", err.Error())
+		resp.Diagnostics.AddError(execErrorSummary(err, "Command execution failed"), err.Error())
 		return
 	}
-	data.Output = types.StringValue(output)
-	data.ExitCode = types.Int64Value(exitCode)
+	data.Output = types.StringValue(result.Output)
+	data.Stdout = types.StringValue(result.Stdout)
+	data.Stderr = types.StringValue(result.Stderr)
+	data.ExitCode = types.Int64Value(result.ExitCode)
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
+// configStdin returns the standard input of the command, which is write-only and thus
+// only available from the configuration
+func configStdin(ctx context.Context, config tfsdk.Config) ([]byte, diag.Diagnostics) {
+	var data SSHExecResourceModel
+	diags := config.Get(ctx, &data)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	stdin, err := stdinBytes(data.Stdin, data.StdinBase64)
+	if err != nil {
+		diags.AddAttributeError(path.Root("stdin_base64"), "Invalid stdin_base64", err.Error())
+	}
+	return stdin, diags
+}
+
 func (r *SSHExecResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
 	var data SSHExecResourceModel
 
@@ -159,11 +413,18 @@ func (r *SSHExecResource) Update(ctx context.Context, req resource.UpdateRequest
 	// Preserve the original ID from state
 	data.Id = state.Id
 
+	stdin, diags := configStdin(ctx, req.Config)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	// Get SSH client
 	client, err := r.manager.GetClient(
-		*data.SSHConnectionModel.toConfig(),
+		ctx,
+		connectionConfig(data.ConnectionName, &data.SSHConnectionModel),
 		data.UseProviderAsBastion.ValueBool(),
-		data.Bastion.toConfig(),
+		jumpHostConfigs(data.Bastion, data.JumpHosts),
 		nil,
 	)
 	if err != nil {
@@ -172,17 +433,21 @@ func (r *SSHExecResource) Update(ctx context.Context, req resource.UpdateRequest
 	}
 
 	// Execute the command
-	output, exitCode, err := executeCommand(
+	result, err := executeCommand(
+		ctx,
+		r.manager,
 		client,
 		data.Command.ValueString(),
-		data.FailIfNonzero.ValueBool(),
+		data.execOptions(r.manager, stdin),
 	)
 	if err != nil {
-		resp.Diagnostics.AddError("Command execution failed", err.Error())
+		resp.Diagnostics.AddError(execErrorSummary(err, "Command execution failed"), err.Error())
 		return
 	}
-	data.Output = types.StringValue(output)
-	data.ExitCode = types.Int64Value(exitCode)
+	data.Output = types.StringValue(result.Output)
+	data.Stdout = types.StringValue(result.Stdout)
+	data.Stderr = types.StringValue(result.Stderr)
+	data.ExitCode = types.Int64Value(result.ExitCode)
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
@@ -199,9 +464,10 @@ func (r *SSHExecResource) Delete(ctx context.Context, req resource.DeleteRequest
 	if !data.OnDestroy.IsNull() {
 		// Get SSH client
 		client, err := r.manager.GetClient(
-			*data.SSHConnectionModel.toConfig(),
+			ctx,
+			connectionConfig(data.ConnectionName, &data.SSHConnectionModel),
 			data.UseProviderAsBastion.ValueBool(),
-			data.Bastion.toConfig(),
+			jumpHostConfigs(data.Bastion, data.JumpHosts),
 			nil,
 		)
 		if err != nil {
@@ -209,13 +475,16 @@ func (r *SSHExecResource) Delete(ctx context.Context, req resource.DeleteRequest
 			return
 		}
 
-		_, _, err = executeCommand(
+		_, err = executeCommand(
+			ctx,
+			r.manager,
 			client,
 			data.OnDestroy.ValueString(),
-			data.FailIfNonzero.ValueBool(),
+			// The standard input is write-only, and thus not available on destroy
+			data.execOptions(r.manager, nil),
 		)
 		if err != nil {
-			resp.Diagnostics.AddError("Failed to execute destroy command", err.Error())
+			resp.Diagnostics.AddError(execErrorSummary(err, "Failed to execute destroy command"), err.Error())
 			return
 		}
 	}
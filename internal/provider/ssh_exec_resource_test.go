@@ -2,9 +2,13 @@ package provider
 
 import (
 	"fmt"
+	"io"
+	"net"
 	"testing"
 
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
 )
 
 func TestAccSSHExecResource(t *testing.T) {
@@ -48,6 +52,84 @@ func TestAccSSHExecResource(t *testing.T) {
 	})
 }
 
+func TestAccSSHExecResource_RemoteForwards(t *testing.T) {
+	// Local service the command reaches through the forwarded port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			io.WriteString(conn, "artifact\n")
+			conn.Close()
+		}
+	}()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSSHExecResourceConfigRemoteForwards(t, listener.Addr().(*net.TCPAddr).Port),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_exec.forwarded", "exit_code", "0"),
+					resource.TestCheckResourceAttr("ssh_exec.forwarded", "output", "artifact\n"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSSHExecResource_Stdin(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck: func() { testAccPreCheck(t) },
+		// Write-only attributes require Terraform 1.11
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(version.Must(version.NewVersion("1.11.0"))),
+		},
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSSHExecResourceConfigStdin(t, "secret"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_exec.stdin", "stdout", "secret"),
+					resource.TestCheckNoResourceAttr("ssh_exec.stdin", "stdin"),
+					resource.TestCheckResourceAttr("ssh_exec.stdin", "stdin_sha256", "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"),
+				),
+			},
+			// Changing the input runs the command again
+			{
+				Config: testAccSSHExecResourceConfigStdin(t, "updated"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_exec.stdin", "stdout", "updated"),
+					resource.TestCheckNoResourceAttr("ssh_exec.stdin", "stdin"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSSHExecResource_Become(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSSHExecResourceConfigBecome(t),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_exec.become", "stdout", "root\n"),
+					resource.TestCheckResourceAttr("ssh_exec.no_become", "stdout", getEnvVarOrSkip(t, "SSH_USER")+"\n"),
+				),
+			},
+		},
+	})
+}
+
 func testAccSSHExecResourceConfig(t *testing.T) string {
 	return fmt.Sprintf(`
 provider "ssh" {
@@ -112,3 +194,57 @@ resource "ssh_exec" "multiline" {
 }
 `, getEnvVarOrSkip(t, "SSH_HOST"), getEnvVarOrSkip(t, "SSH_USER"), getEnvVarOrSkip(t, "SSH_PASSWORD"))
 }
+
+func testAccSSHExecResourceConfigRemoteForwards(t *testing.T, localPort int) string {
+	return fmt.Sprintf(`
+provider "ssh" {
+  host     = "%s"
+  user     = "%s"
+  password = "%s"
+}
+
+resource "ssh_exec" "forwarded" {
+  command = "bash -c 'exec 3<>/dev/tcp/127.0.0.1/18080 && cat <&3'"
+
+  remote_forwards = [{
+    remote_port = 18080
+    local_port  = %d
+  }]
+}
+`, getEnvVarOrSkip(t, "SSH_HOST"), getEnvVarOrSkip(t, "SSH_USER"), getEnvVarOrSkip(t, "SSH_PASSWORD"), localPort)
+}
+
+func testAccSSHExecResourceConfigStdin(t *testing.T, stdin string) string {
+	return fmt.Sprintf(`
+provider "ssh" {
+  host     = "%s"
+  user     = "%s"
+  password = "%s"
+}
+
+resource "ssh_exec" "stdin" {
+  command = "cat"
+  stdin   = "%s"
+}
+`, getEnvVarOrSkip(t, "SSH_HOST"), getEnvVarOrSkip(t, "SSH_USER"), getEnvVarOrSkip(t, "SSH_PASSWORD"), stdin)
+}
+
+func testAccSSHExecResourceConfigBecome(t *testing.T) string {
+	return fmt.Sprintf(`
+provider "ssh" {
+  host            = "%s"
+  user            = "%s"
+  password        = "%s"
+  become_password = "%s"
+}
+
+resource "ssh_exec" "become" {
+  command = "whoami"
+  become  = true
+}
+
+resource "ssh_exec" "no_become" {
+  command = "whoami"
+}
+`, getEnvVarOrSkip(t, "SSH_HOST"), getEnvVarOrSkip(t, "SSH_USER"), getEnvVarOrSkip(t, "SSH_PASSWORD"), getEnvVarOrSkip(t, "SSH_BECOME_PASSWORD"))
+}
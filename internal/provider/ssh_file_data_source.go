@@ -17,10 +17,15 @@ type SSHFileDataSourceModel struct {
 	FailIfAbsent types.Bool   `tfsdk:"fail_if_absent"`
 	Id           types.String `tfsdk:"id"`
 
+	// Privilege escalation
+	SSHBecomeModel
+
 	// Connection details
 	SSHConnectionModel
-	UseProviderAsBastion types.Bool          `tfsdk:"use_provider_as_bastion"`
-	Bastion              *SSHConnectionModel `tfsdk:"bastion"`
+	UseProviderAsBastion types.Bool           `tfsdk:"use_provider_as_bastion"`
+	ConnectionName       types.String         `tfsdk:"connection_name"`
+	Bastion              *SSHConnectionModel  `tfsdk:"bastion"`
+	JumpHosts            []SSHConnectionModel `tfsdk:"jump_hosts"`
 }
 
 var SSHFileDataSourceSchema = schema.Schema{
@@ -32,18 +37,52 @@ var SSHFileDataSourceSchema = schema.Schema{
 		"fail_if_absent": schema.BoolAttribute{Optional: true, Description: "Whether to fail if the file does not exist"},
 		"id":             schema.StringAttribute{Computed: true, Description: "Unique identifier for this file"},
 
+		// Privilege escalation
+		"become":          SSHBecomeSchema.Become,
+		"become_user":     SSHBecomeSchema.BecomeUser,
+		"become_method":   SSHBecomeSchema.BecomeMethod,
+		"become_password": SSHBecomeSchema.BecomePassword,
+
 		// Common SSH connection attributes
-		"host":                    SSHConnectionSchema.Host,
-		"user":                    SSHConnectionSchema.User,
-		"password":                SSHConnectionSchema.Password,
-		"private_key":             SSHConnectionSchema.PrivateKey,
-		"port":                    SSHConnectionSchema.Port,
-		"use_provider_as_bastion": SSHConnectionSchema.UseProviderAsBastion,
-		"bastion":                 SSHConnectionSchema.Bastion,
+		"host":                         SSHConnectionSchema.Host,
+		"user":                         SSHConnectionSchema.User,
+		"password":                     SSHConnectionSchema.Password,
+		"private_key":                  SSHConnectionSchema.PrivateKey,
+		"private_key_passphrase":       SSHConnectionSchema.PrivateKeyPassphrase,
+		"private_key_path":             SSHConnectionSchema.PrivateKeyPath,
+		"password_file":                SSHConnectionSchema.PasswordFile,
+		"keyboard_interactive_answers": SSHConnectionSchema.KeyboardInteractiveAnswers,
+		"totp_secret":                  SSHConnectionSchema.TOTPSecret,
+		"certificate":                  SSHConnectionSchema.Certificate,
+		"port":                         SSHConnectionSchema.Port,
+		"proxy_command":                SSHConnectionSchema.ProxyCommand,
+		"proxy":                        SSHConnectionSchema.Proxy,
+		"agent":                        SSHConnectionSchema.Agent,
+		"agent_socket":                 SSHConnectionSchema.AgentSocket,
+		"agent_identity":               SSHConnectionSchema.AgentIdentity,
+		"known_hosts_files":            SSHConnectionSchema.KnownHostsFiles,
+		"host_keys":                    SSHConnectionSchema.HostKeys,
+		"strict_host_key_checking":     SSHConnectionSchema.StrictHostKeyChecking,
+		"connect_timeout":              SSHConnectionSchema.ConnectTimeout,
+		"connect_retries":              SSHConnectionSchema.ConnectRetries,
+		"connect_retry_timeout":        SSHConnectionSchema.ConnectRetryTimeout,
+		"connect_retry_interval":       SSHConnectionSchema.ConnectRetryInterval,
+		"connect_retry_max_interval":   SSHConnectionSchema.ConnectRetryMaxInterval,
+		"keepalive_interval":           SSHConnectionSchema.KeepaliveInterval,
+		"keepalive_count_max":          SSHConnectionSchema.KeepaliveCountMax,
+		"ciphers":                      SSHConnectionSchema.Ciphers,
+		"key_exchanges":                SSHConnectionSchema.KeyExchanges,
+		"macs":                         SSHConnectionSchema.MACs,
+		"host_key_algorithms":          SSHConnectionSchema.HostKeyAlgorithms,
+		"use_provider_as_bastion":      SSHConnectionSchema.UseProviderAsBastion,
+		"connection_name":              SSHConnectionSchema.ConnectionName,
+		"bastion":                      SSHConnectionSchema.Bastion,
+		"jump_hosts":                   SSHConnectionSchema.JumpHosts,
 	},
 }
 
 var _ datasource.DataSource = &SSHFileDataSource{}
+var _ datasource.DataSourceWithValidateConfig = &SSHFileDataSource{}
 
 func NewSSHFileDataSource() datasource.DataSource {
 	return &SSHFileDataSource{}
@@ -78,6 +117,17 @@ func (d *SSHFileDataSource) Configure(_ context.Context, req datasource.Configur
 	d.manager = manager
 }
 
+func (d *SSHFileDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var data SSHFileDataSourceModel
+
+	// Nested objects that are not known yet are validated once they are
+	if diags := req.Config.Get(ctx, &data); diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(validateConnection(d.manager, data.ConnectionName, &data.SSHConnectionModel, data.UseProviderAsBastion, data.Bastion, data.JumpHosts)...)
+}
+
 func (d *SSHFileDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
 	var data SSHFileDataSourceModel
 
@@ -90,9 +140,10 @@ func (d *SSHFileDataSource) Read(ctx context.Context, req datasource.ReadRequest
 	data.Id = types.StringValue(generateFileID(data.Path.ValueString(), time.Now()))
 
 	client, err := d.manager.GetClient(
-		*data.SSHConnectionModel.toConfig(),
+		ctx,
+		connectionConfig(data.ConnectionName, &data.SSHConnectionModel),
 		data.UseProviderAsBastion.ValueBool(),
-		data.Bastion.toConfig(),
+		jumpHostConfigs(data.Bastion, data.JumpHosts),
 		nil,
 	)
 	if err != nil {
@@ -100,7 +151,7 @@ func (d *SSHFileDataSource) Read(ctx context.Context, req datasource.ReadRequest
 		return
 	}
 
-	content, err := readFile(client, data.Path.ValueString())
+	content, err := readFile(ctx, d.manager, client, data.Path.ValueString(), d.manager.resolveBecome(data.ConnectionName, &data.SSHBecomeModel))
 	if err != nil {
 		if data.FailIfAbsent.ValueBool() {
 			resp.Diagnostics.AddError("Failed to read file", err.Error())
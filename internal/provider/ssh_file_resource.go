@@ -20,10 +20,15 @@ type SSHFileResourceModel struct {
 	DeleteOnDestroy types.Bool   `tfsdk:"delete_on_destroy"`
 	Id              types.String `tfsdk:"id"`
 
+	// Privilege escalation
+	SSHBecomeModel
+
 	// Connection details
 	SSHConnectionModel
-	UseProviderAsBastion types.Bool          `tfsdk:"use_provider_as_bastion"`
-	Bastion              *SSHConnectionModel `tfsdk:"bastion"`
+	UseProviderAsBastion types.Bool           `tfsdk:"use_provider_as_bastion"`
+	ConnectionName       types.String         `tfsdk:"connection_name"`
+	Bastion              *SSHConnectionModel  `tfsdk:"bastion"`
+	JumpHosts            []SSHConnectionModel `tfsdk:"jump_hosts"`
 }
 
 var SSHFileResourceSchema = schema.Schema{
@@ -36,18 +41,52 @@ var SSHFileResourceSchema = schema.Schema{
 		"delete_on_destroy": schema.BoolAttribute{Optional: true, Computed: true, Default: booldefault.StaticBool(true), Description: "Whether to delete the file when the resource is destroyed. Defaults to true."},
 		"id":                schema.StringAttribute{Computed: true, Description: "Unique identifier for this file"},
 
+		// Privilege escalation
+		"become":          SSHBecomeSchema.Become,
+		"become_user":     SSHBecomeSchema.BecomeUser,
+		"become_method":   SSHBecomeSchema.BecomeMethod,
+		"become_password": SSHBecomeSchema.BecomePassword,
+
 		// Common SSH connection attributes
-		"host":                    SSHConnectionSchema.Host,
-		"user":                    SSHConnectionSchema.User,
-		"password":                SSHConnectionSchema.Password,
-		"private_key":             SSHConnectionSchema.PrivateKey,
-		"port":                    SSHConnectionSchema.Port,
-		"use_provider_as_bastion": SSHConnectionSchema.UseProviderAsBastion,
-		"bastion":                 SSHConnectionSchema.Bastion,
+		"host":                         SSHConnectionSchema.Host,
+		"user":                         SSHConnectionSchema.User,
+		"password":                     SSHConnectionSchema.Password,
+		"private_key":                  SSHConnectionSchema.PrivateKey,
+		"private_key_passphrase":       SSHConnectionSchema.PrivateKeyPassphrase,
+		"private_key_path":             SSHConnectionSchema.PrivateKeyPath,
+		"password_file":                SSHConnectionSchema.PasswordFile,
+		"keyboard_interactive_answers": SSHConnectionSchema.KeyboardInteractiveAnswers,
+		"totp_secret":                  SSHConnectionSchema.TOTPSecret,
+		"certificate":                  SSHConnectionSchema.Certificate,
+		"port":                         SSHConnectionSchema.Port,
+		"proxy_command":                SSHConnectionSchema.ProxyCommand,
+		"proxy":                        SSHConnectionSchema.Proxy,
+		"agent":                        SSHConnectionSchema.Agent,
+		"agent_socket":                 SSHConnectionSchema.AgentSocket,
+		"agent_identity":               SSHConnectionSchema.AgentIdentity,
+		"known_hosts_files":            SSHConnectionSchema.KnownHostsFiles,
+		"host_keys":                    SSHConnectionSchema.HostKeys,
+		"strict_host_key_checking":     SSHConnectionSchema.StrictHostKeyChecking,
+		"connect_timeout":              SSHConnectionSchema.ConnectTimeout,
+		"connect_retries":              SSHConnectionSchema.ConnectRetries,
+		"connect_retry_timeout":        SSHConnectionSchema.ConnectRetryTimeout,
+		"connect_retry_interval":       SSHConnectionSchema.ConnectRetryInterval,
+		"connect_retry_max_interval":   SSHConnectionSchema.ConnectRetryMaxInterval,
+		"keepalive_interval":           SSHConnectionSchema.KeepaliveInterval,
+		"keepalive_count_max":          SSHConnectionSchema.KeepaliveCountMax,
+		"ciphers":                      SSHConnectionSchema.Ciphers,
+		"key_exchanges":                SSHConnectionSchema.KeyExchanges,
+		"macs":                         SSHConnectionSchema.MACs,
+		"host_key_algorithms":          SSHConnectionSchema.HostKeyAlgorithms,
+		"use_provider_as_bastion":      SSHConnectionSchema.UseProviderAsBastion,
+		"connection_name":              SSHConnectionSchema.ConnectionName,
+		"bastion":                      SSHConnectionSchema.Bastion,
+		"jump_hosts":                   SSHConnectionSchema.JumpHosts,
 	},
 }
 
 var _ resource.Resource = &SSHFileResource{}
+var _ resource.ResourceWithValidateConfig = &SSHFileResource{}
 
 func NewSSHFileResource() resource.Resource {
 	return &SSHFileResource{}
@@ -82,6 +121,17 @@ func (r *SSHFileResource) Configure(_ context.Context, req resource.ConfigureReq
 	r.manager = manager
 }
 
+func (r *SSHFileResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data SSHFileResourceModel
+
+	// Nested objects that are not known yet are validated once they are
+	if diags := req.Config.Get(ctx, &data); diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(validateConnection(r.manager, data.ConnectionName, &data.SSHConnectionModel, data.UseProviderAsBastion, data.Bastion, data.JumpHosts)...)
+}
+
 func (r *SSHFileResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
 	var data SSHFileResourceModel
 
@@ -94,9 +144,10 @@ func (r *SSHFileResource) Create(ctx context.Context, req resource.CreateRequest
 	data.Id = types.StringValue(generateFileID(data.Path.ValueString(), time.Now()))
 
 	client, err := r.manager.GetClient(
-		*data.SSHConnectionModel.toConfig(),
+		ctx,
+		connectionConfig(data.ConnectionName, &data.SSHConnectionModel),
 		data.UseProviderAsBastion.ValueBool(),
-		data.Bastion.toConfig(),
+		jumpHostConfigs(data.Bastion, data.JumpHosts),
 		nil,
 	)
 	if err != nil {
@@ -104,7 +155,7 @@ func (r *SSHFileResource) Create(ctx context.Context, req resource.CreateRequest
 		return
 	}
 
-	if err := writeFile(ctx, client, data.Path.ValueString(), data.Content.ValueString(), data.Permissions.ValueString()); err != nil {
+	if err := writeFile(ctx, r.manager, client, data.Path.ValueString(), data.Content.ValueString(), data.Permissions.ValueString(), r.manager.resolveBecome(data.ConnectionName, &data.SSHBecomeModel)); err != nil {
 		resp.Diagnostics.AddError("Failed to write file", err.Error())
 		return
 	}
@@ -121,9 +172,10 @@ func (r *SSHFileResource) Read(ctx context.Context, req resource.ReadRequest, re
 	}
 
 	client, err := r.manager.GetClient(
-		*data.SSHConnectionModel.toConfig(),
+		ctx,
+		connectionConfig(data.ConnectionName, &data.SSHConnectionModel),
 		data.UseProviderAsBastion.ValueBool(),
-		data.Bastion.toConfig(),
+		jumpHostConfigs(data.Bastion, data.JumpHosts),
 		nil,
 	)
 	if err != nil {
@@ -131,7 +183,7 @@ func (r *SSHFileResource) Read(ctx context.Context, req resource.ReadRequest, re
 		return
 	}
 
-	content, err := readFile(client, data.Path.ValueString())
+	content, err := readFile(ctx, r.manager, client, data.Path.ValueString(), r.manager.resolveBecome(data.ConnectionName, &data.SSHBecomeModel))
 	if err != nil {
 		resp.State.RemoveResource(ctx)
 		return
@@ -161,9 +213,10 @@ func (r *SSHFileResource) Update(ctx context.Context, req resource.UpdateRequest
 	data.Id = state.Id
 
 	client, err := r.manager.GetClient(
-		*data.SSHConnectionModel.toConfig(),
+		ctx,
+		connectionConfig(data.ConnectionName, &data.SSHConnectionModel),
 		data.UseProviderAsBastion.ValueBool(),
-		data.Bastion.toConfig(),
+		jumpHostConfigs(data.Bastion, data.JumpHosts),
 		nil,
 	)
 	if err != nil {
@@ -171,7 +224,7 @@ func (r *SSHFileResource) Update(ctx context.Context, req resource.UpdateRequest
 		return
 	}
 
-	if err := writeFile(ctx, client, data.Path.ValueString(), data.Content.ValueString(), data.Permissions.ValueString()); err != nil {
+	if err := writeFile(ctx, r.manager, client, data.Path.ValueString(), data.Content.ValueString(), data.Permissions.ValueString(), r.manager.resolveBecome(data.ConnectionName, &data.SSHBecomeModel)); err != nil {
 		resp.Diagnostics.AddError("Failed to update file", err.Error())
 		return
 	}
@@ -194,9 +247,10 @@ func (r *SSHFileResource) Delete(ctx context.Context, req resource.DeleteRequest
 	}
 
 	client, err := r.manager.GetClient(
-		*data.SSHConnectionModel.toConfig(),
+		ctx,
+		connectionConfig(data.ConnectionName, &data.SSHConnectionModel),
 		data.UseProviderAsBastion.ValueBool(),
-		data.Bastion.toConfig(),
+		jumpHostConfigs(data.Bastion, data.JumpHosts),
 		nil,
 	)
 	if err != nil {
@@ -204,7 +258,7 @@ func (r *SSHFileResource) Delete(ctx context.Context, req resource.DeleteRequest
 		return
 	}
 
-	if err := deleteFile(client, data.Path.ValueString()); err != nil {
+	if err := deleteFile(ctx, r.manager, client, data.Path.ValueString(), r.manager.resolveBecome(data.ConnectionName, &data.SSHBecomeModel)); err != nil {
 		resp.Diagnostics.AddError("Failed to delete file", err.Error())
 		return
 	}
@@ -114,3 +114,51 @@ resource "ssh_file" "test_write" {
 }
 `, getEnvVarOrSkip(t, "SSH_HOST"), getEnvVarOrSkip(t, "SSH_USER"), getEnvVarOrSkip(t, "SSH_PASSWORD"))
 }
+
+func TestAccSSHFileResource_Become(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSSHFileResourceConfigBecome(t, "root only"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.privileged", "content", "root only"),
+					resource.TestCheckResourceAttr("data.ssh_file.privileged", "content", "root only"),
+				),
+			},
+			// Updates replace the file through the privileged staging file as well
+			{
+				Config: testAccSSHFileResourceConfigBecome(t, "updated"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.privileged", "content", "updated"),
+					resource.TestCheckResourceAttr("data.ssh_file.privileged", "content", "updated"),
+				),
+			},
+		},
+	})
+}
+
+func testAccSSHFileResourceConfigBecome(t *testing.T, content string) string {
+	return fmt.Sprintf(`
+provider "ssh" {
+  host            = "%s"
+  user            = "%s"
+  password        = "%s"
+  become          = true
+  become_password = "%s"
+}
+
+resource "ssh_file" "privileged" {
+  path        = "/root/terraform/become.txt"
+  content     = "%s"
+  permissions = "0600"
+}
+
+data "ssh_file" "privileged" {
+  path = ssh_file.privileged.path
+
+  depends_on = [ssh_file.privileged]
+}
+`, getEnvVarOrSkip(t, "SSH_HOST"), getEnvVarOrSkip(t, "SSH_USER"), getEnvVarOrSkip(t, "SSH_PASSWORD"), getEnvVarOrSkip(t, "SSH_BECOME_PASSWORD"), content)
+}
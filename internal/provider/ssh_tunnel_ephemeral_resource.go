@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultTunnelLocalHost keeps tunnels reachable from the local machine only
+const defaultTunnelLocalHost = "127.0.0.1"
+
+// tunnelPrivateKey is the private data key holding the ID of an open tunnel
+const tunnelPrivateKey = "tunnel_id"
+
+type SSHTunnelEphemeralResourceModel struct {
+	RemoteHost types.String `tfsdk:"remote_host"`
+	RemotePort types.Int64  `tfsdk:"remote_port"`
+	LocalHost  types.String `tfsdk:"local_host"`
+	LocalPort  types.Int64  `tfsdk:"local_port"`
+
+	// Connection details
+	SSHConnectionModel
+	UseProviderAsBastion types.Bool           `tfsdk:"use_provider_as_bastion"`
+	ConnectionName       types.String         `tfsdk:"connection_name"`
+	Bastion              *SSHConnectionModel  `tfsdk:"bastion"`
+	JumpHosts            []SSHConnectionModel `tfsdk:"jump_hosts"`
+}
+
+var SSHTunnelEphemeralResourceSchema = schema.Schema{
+	Description: "Forward a local port through SSH to a host and port reachable from the SSH server, for the duration of the Terraform operation",
+	Attributes: map[string]schema.Attribute{
+		"remote_host": schema.StringAttribute{Required: true, Description: "Host to forward connections to, resolved by the SSH server (e.g. 'localhost' for a service on the SSH server itself)"},
+		"remote_port": schema.Int64Attribute{Required: true, Description: "Port to forward connections to", Validators: portValidators},
+		"local_host":  schema.StringAttribute{Optional: true, Computed: true, Description: "Local address to listen on. Defaults to '127.0.0.1'"},
+		"local_port":  schema.Int64Attribute{Optional: true, Computed: true, Description: "Local port to listen on. Defaults to a free port chosen by the operating system", Validators: portValidators},
+
+		// Common SSH connection attributes
+		"host":                         SSHConnectionSchema.Host,
+		"user":                         SSHConnectionSchema.User,
+		"password":                     SSHConnectionSchema.Password,
+		"private_key":                  SSHConnectionSchema.PrivateKey,
+		"private_key_passphrase":       SSHConnectionSchema.PrivateKeyPassphrase,
+		"private_key_path":             SSHConnectionSchema.PrivateKeyPath,
+		"password_file":                SSHConnectionSchema.PasswordFile,
+		"keyboard_interactive_answers": SSHConnectionSchema.KeyboardInteractiveAnswers,
+		"totp_secret":                  SSHConnectionSchema.TOTPSecret,
+		"certificate":                  SSHConnectionSchema.Certificate,
+		"port":                         SSHConnectionSchema.Port,
+		"proxy_command":                SSHConnectionSchema.ProxyCommand,
+		"proxy":                        SSHConnectionSchema.Proxy,
+		"agent":                        SSHConnectionSchema.Agent,
+		"agent_socket":                 SSHConnectionSchema.AgentSocket,
+		"agent_identity":               SSHConnectionSchema.AgentIdentity,
+		"known_hosts_files":            SSHConnectionSchema.KnownHostsFiles,
+		"host_keys":                    SSHConnectionSchema.HostKeys,
+		"strict_host_key_checking":     SSHConnectionSchema.StrictHostKeyChecking,
+		"connect_timeout":              SSHConnectionSchema.ConnectTimeout,
+		"connect_retries":              SSHConnectionSchema.ConnectRetries,
+		"connect_retry_timeout":        SSHConnectionSchema.ConnectRetryTimeout,
+		"connect_retry_interval":       SSHConnectionSchema.ConnectRetryInterval,
+		"connect_retry_max_interval":   SSHConnectionSchema.ConnectRetryMaxInterval,
+		"keepalive_interval":           SSHConnectionSchema.KeepaliveInterval,
+		"keepalive_count_max":          SSHConnectionSchema.KeepaliveCountMax,
+		"ciphers":                      SSHConnectionSchema.Ciphers,
+		"key_exchanges":                SSHConnectionSchema.KeyExchanges,
+		"macs":                         SSHConnectionSchema.MACs,
+		"host_key_algorithms":          SSHConnectionSchema.HostKeyAlgorithms,
+		"use_provider_as_bastion":      SSHConnectionSchema.UseProviderAsBastion,
+		"connection_name":              SSHConnectionSchema.ConnectionName,
+		"bastion":                      SSHConnectionSchema.Bastion,
+		"jump_hosts":                   SSHConnectionSchema.JumpHosts,
+	},
+}
+
+var _ ephemeral.EphemeralResource = &SSHTunnelEphemeralResource{}
+var _ ephemeral.EphemeralResourceWithConfigure = &SSHTunnelEphemeralResource{}
+var _ ephemeral.EphemeralResourceWithValidateConfig = &SSHTunnelEphemeralResource{}
+var _ ephemeral.EphemeralResourceWithClose = &SSHTunnelEphemeralResource{}
+
+func NewSSHTunnelEphemeralResource() ephemeral.EphemeralResource {
+	return &SSHTunnelEphemeralResource{}
+}
+
+type SSHTunnelEphemeralResource struct {
+	manager *SSHManager
+}
+
+func (e *SSHTunnelEphemeralResource) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_tunnel"
+}
+
+func (e *SSHTunnelEphemeralResource) Schema(_ context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = SSHTunnelEphemeralResourceSchema
+}
+
+func (e *SSHTunnelEphemeralResource) Configure(_ context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	manager, ok := req.ProviderData.(*SSHManager)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Ephemeral Resource Configure Type",
+			fmt.Sprintf("Expected *SSHManager, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	e.manager = manager
+}
+
+func (e *SSHTunnelEphemeralResource) ValidateConfig(ctx context.Context, req ephemeral.ValidateConfigRequest, resp *ephemeral.ValidateConfigResponse) {
+	var data SSHTunnelEphemeralResourceModel
+
+	// Nested objects that are not known yet are validated once they are
+	if diags := req.Config.Get(ctx, &data); diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(validateConnection(e.manager, data.ConnectionName, &data.SSHConnectionModel, data.UseProviderAsBastion, data.Bastion, data.JumpHosts)...)
+}
+
+func (e *SSHTunnelEphemeralResource) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	var data SSHTunnelEphemeralResourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if data.LocalHost.IsNull() {
+		data.LocalHost = types.StringValue(defaultTunnelLocalHost)
+	}
+
+	// Connect before listening, so that connection errors surface while opening the tunnel.
+	// Every forwarded connection gets the client again, which re-dials it once it died.
+	dial := func(ctx context.Context) (*ssh.Client, error) {
+		return e.manager.GetClient(
+			ctx,
+			connectionConfig(data.ConnectionName, &data.SSHConnectionModel),
+			data.UseProviderAsBastion.ValueBool(),
+			jumpHostConfigs(data.Bastion, data.JumpHosts),
+			nil,
+		)
+	}
+	if _, err := dial(ctx); err != nil {
+		resp.Diagnostics.AddError("Failed to get SSH client", err.Error())
+		return
+	}
+
+	local := net.JoinHostPort(data.LocalHost.ValueString(), strconv.FormatInt(data.LocalPort.ValueInt64(), 10))
+	remote := net.JoinHostPort(data.RemoteHost.ValueString(), strconv.FormatInt(data.RemotePort.ValueInt64(), 10))
+	id, addr, err := e.manager.OpenTunnel(ctx, local, remote, dial)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to open tunnel", err.Error())
+		return
+	}
+	data.LocalPort = types.Int64Value(int64(addr.Port))
+
+	value, err := json.Marshal(id)
+	if err != nil {
+		e.manager.CloseTunnel(id)
+		resp.Diagnostics.AddError("Failed to open tunnel", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, tunnelPrivateKey, value)...)
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		e.manager.CloseTunnel(id)
+	}
+}
+
+func (e *SSHTunnelEphemeralResource) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	value, diags := req.Private.GetKey(ctx, tunnelPrivateKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() || value == nil {
+		return
+	}
+
+	var id string
+	if err := json.Unmarshal(value, &id); err != nil {
+		resp.Diagnostics.AddError("Failed to close tunnel", err.Error())
+		return
+	}
+	e.manager.CloseTunnel(id)
+}
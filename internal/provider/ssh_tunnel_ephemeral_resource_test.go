@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-testing/echoprovider"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestAccSSHTunnelEphemeralResource(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck: func() { testAccPreCheck(t) },
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"ssh":  testAccProtoV6ProviderFactories["ssh"],
+			"echo": echoprovider.NewProviderServer(),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSSHTunnelEphemeralResourceConfig(t),
+				ConfigStateChecks: []statecheck.StateCheck{
+					// Tunnel to the SSH server itself
+					statecheck.ExpectKnownValue("echo.tunnel", tfjsonpath.New("data").AtMapKey("local_host"), knownvalue.StringExact("127.0.0.1")),
+					statecheck.ExpectKnownValue("echo.tunnel", tfjsonpath.New("data").AtMapKey("local_port"), knownvalue.NotNull()),
+					statecheck.ExpectKnownValue("echo.tunnel", tfjsonpath.New("data").AtMapKey("remote_port"), knownvalue.Int64Exact(22)),
+				},
+			},
+		},
+	})
+}
+
+func TestAccSSHTunnelEphemeralResource_UnknownConnection(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck: func() { testAccPreCheck(t) },
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+provider "ssh" {
+  host     = "%s"
+  user     = "%s"
+  password = "%s"
+}
+
+ephemeral "ssh_tunnel" "missing" {
+  connection_name = "missing"
+  remote_host     = "127.0.0.1"
+  remote_port     = 22
+}
+`, getEnvVarOrSkip(t, "SSH_HOST"), getEnvVarOrSkip(t, "SSH_USER"), getEnvVarOrSkip(t, "SSH_PASSWORD")),
+				ExpectError: regexp.MustCompile(`Unknown connection`),
+			},
+		},
+	})
+}
+
+func testAccSSHTunnelEphemeralResourceConfig(t *testing.T) string {
+	return fmt.Sprintf(`
+provider "ssh" {
+  host     = "%s"
+  user     = "%s"
+  password = "%s"
+}
+
+ephemeral "ssh_tunnel" "self" {
+  remote_host = "127.0.0.1"
+  remote_port = 22
+}
+
+provider "echo" {
+  data = ephemeral.ssh_tunnel.self
+}
+
+resource "echo" "tunnel" {}
+`, getEnvVarOrSkip(t, "SSH_HOST"), getEnvVarOrSkip(t, "SSH_USER"), getEnvVarOrSkip(t, "SSH_PASSWORD"))
+}
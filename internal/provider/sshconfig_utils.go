@@ -0,0 +1,182 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// defaultSSHConfigFile is read when use_ssh_config is enabled without an explicit file
+const defaultSSHConfigFile = "~/.ssh/config"
+
+// loadSSHConfig parses the OpenSSH client configuration file. A missing default file
+// is treated as empty, while a missing explicitly configured file is an error.
+func loadSSHConfig(file *string) (*ssh_config.Config, error) {
+	path := defaultSSHConfigFile
+	if file != nil {
+		path = *file
+	}
+	path = expandPath(path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && file == nil {
+			return &ssh_config.Config{}, nil
+		}
+		return nil, fmt.Errorf("unable to open ssh config file: %w", err)
+	}
+	defer f.Close()
+
+	cfg, err := ssh_config.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse ssh config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// applySSHConfig resolves a host alias using the OpenSSH client configuration. Values
+// already present in the connection config take precedence over the config file. The
+// returned jump hosts are taken from ProxyJump and are already resolved themselves.
+// ProxyCommand is used instead of ProxyJump when set.
+func applySSHConfig(cfg *ssh_config.Config, config SSHConnectionConfig) (SSHConnectionConfig, []SSHConnectionConfig, error) {
+	if cfg == nil || config.Host == nil || config.sshConfigApplied {
+		return config, nil, nil
+	}
+	alias := *config.Host
+	config.sshConfigApplied = true
+
+	get := func(key string) string {
+		value, _ := cfg.Get(alias, key)
+		return value
+	}
+
+	if hostName := get("HostName"); hostName != "" {
+		hostName = strings.ReplaceAll(hostName, "%h", alias)
+		config.Host = &hostName
+	}
+	if config.User == nil {
+		if user := get("User"); user != "" {
+			config.User = &user
+		}
+	}
+	if config.Port == nil {
+		if value := get("Port"); value != "" {
+			port, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return config, nil, fmt.Errorf("invalid Port %q for host %s in ssh config: %w", value, alias, err)
+			}
+			config.Port = &port
+		}
+	}
+	if config.PrivateKey == nil && config.PrivateKeyPath == nil && (config.Agent == nil || !*config.Agent) {
+		identityFiles, _ := cfg.GetAll(alias, "IdentityFile")
+		for _, identityFile := range identityFiles {
+			content, err := os.ReadFile(expandPath(identityFile))
+			if err != nil {
+				continue
+			}
+			value := string(content)
+			config.PrivateKey = &value
+			break
+		}
+	}
+	if config.StrictHostKeyChecking == nil {
+		switch strings.ToLower(get("StrictHostKeyChecking")) {
+		case "yes", "ask":
+			policy := hostKeyPolicyStrict
+			config.StrictHostKeyChecking = &policy
+		case "accept-new":
+			policy := hostKeyPolicyAcceptNew
+			config.StrictHostKeyChecking = &policy
+		case "no", "off":
+			policy := hostKeyPolicyOff
+			config.StrictHostKeyChecking = &policy
+		}
+	}
+	if config.KnownHostsFiles == nil {
+		if files := get("UserKnownHostsFile"); files != "" {
+			config.KnownHostsFiles = strings.Fields(files)
+		}
+	}
+
+	// ProxyCommand and ProxyJump are mutually exclusive, an explicit proxy_command wins
+	if config.ProxyCommand == nil {
+		if proxyCommand := get("ProxyCommand"); proxyCommand != "" && !strings.EqualFold(proxyCommand, "none") {
+			config.ProxyCommand = &proxyCommand
+		}
+	}
+	if config.ProxyCommand != nil {
+		return config, nil, nil
+	}
+
+	proxyJump := get("ProxyJump")
+	if proxyJump == "" || strings.EqualFold(proxyJump, "none") {
+		return config, nil, nil
+	}
+
+	var jumpHosts []SSHConnectionConfig
+	for _, spec := range strings.Split(proxyJump, ",") {
+		hop, err := parseJumpHost(strings.TrimSpace(spec))
+		if err != nil {
+			return config, nil, fmt.Errorf("invalid ProxyJump %q for host %s in ssh config: %w", proxyJump, alias, err)
+		}
+
+		// Jump hosts are resolved as well, but their own ProxyJump is not followed. Like the
+		// ssh CLI, they never take the user of the target host, only their own.
+		hop, _, err = applySSHConfig(cfg, hop)
+		if err != nil {
+			return config, nil, err
+		}
+
+		// Jump hosts without an identity of their own offer the keys of the target host,
+		// like the ssh CLI does. Passwords, answers and TOTP secrets belong to the target
+		// alone and are never sent to a jump host.
+		if hop.PrivateKey == nil && hop.Agent == nil {
+			hop.PrivateKey = config.PrivateKey
+			hop.PrivateKeyPath = config.PrivateKeyPath
+			hop.PrivateKeyPassphrase = config.PrivateKeyPassphrase
+			hop.Certificate = config.Certificate
+			hop.Agent = config.Agent
+			hop.AgentSocket = config.AgentSocket
+			hop.AgentIdentity = config.AgentIdentity
+		}
+		if hop.PrivateKey == nil && hop.PrivateKeyPath == nil && (hop.Agent == nil || !*hop.Agent) {
+			return config, nil, fmt.Errorf("no identity is available for jump host %s in the ProxyJump of host %s, set an IdentityFile for it in the ssh config or use a private key or the ssh agent for the connection", *hop.Host, alias)
+		}
+
+		jumpHosts = append(jumpHosts, hop)
+	}
+
+	return config, jumpHosts, nil
+}
+
+// parseJumpHost parses a ProxyJump entry of the form [user@]host[:port]
+func parseJumpHost(spec string) (SSHConnectionConfig, error) {
+	var hop SSHConnectionConfig
+	if spec == "" {
+		return hop, fmt.Errorf("empty jump host")
+	}
+
+	if at := strings.LastIndex(spec, "@"); at != -1 {
+		user := spec[:at]
+		hop.User = &user
+		spec = spec[at+1:]
+	}
+
+	host := spec
+	if h, p, err := net.SplitHostPort(spec); err == nil {
+		port, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return hop, fmt.Errorf("invalid port %q", p)
+		}
+		host = h
+		hop.Port = &port
+	}
+	hop.Host = &host
+
+	return hop, nil
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplySSHConfig(t *testing.T) {
+	dir := t.TempDir()
+	identityFile := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(identityFile, []byte("bastion key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	configFile := filepath.Join(dir, "config")
+	content := `Host prod-db
+  HostName db.internal.example.com
+  User deploy
+  Port 2222
+  StrictHostKeyChecking yes
+  ProxyJump bastion,admin@other:2200
+
+Host bastion
+  HostName bastion.example.com
+  User jump
+  IdentityFile ` + identityFile + `
+
+Host proxied
+  ProxyCommand nc %h %p
+  ProxyJump bastion
+
+Host through-unnamed
+  User deploy
+  ProxyJump gateway.example.com
+
+Host bad-port
+  Port ssh
+`
+	if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadSSHConfig(&configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := func(s string) *string { return &s }
+	agent := true
+
+	tests := []struct {
+		name    string
+		config  SSHConnectionConfig
+		check   func(t *testing.T, config SSHConnectionConfig, jumpHosts []SSHConnectionConfig)
+		wantErr string
+	}{
+		{
+			name:   "resolves alias",
+			config: SSHConnectionConfig{Host: str("prod-db"), PrivateKey: str("target key")},
+			check: func(t *testing.T, config SSHConnectionConfig, jumpHosts []SSHConnectionConfig) {
+				if *config.Host != "db.internal.example.com" || *config.User != "deploy" || *config.Port != 2222 {
+					t.Errorf("unexpected target %s@%s:%d", *config.User, *config.Host, *config.Port)
+				}
+				if *config.StrictHostKeyChecking != hostKeyPolicyStrict {
+					t.Errorf("unexpected strict_host_key_checking %q", *config.StrictHostKeyChecking)
+				}
+				if len(jumpHosts) != 2 {
+					t.Fatalf("expected 2 jump hosts, got %d", len(jumpHosts))
+				}
+				if *jumpHosts[0].Host != "bastion.example.com" || *jumpHosts[0].User != "jump" || *jumpHosts[0].PrivateKey != "bastion key" {
+					t.Errorf("unexpected first jump host %+v", jumpHosts[0])
+				}
+				if *jumpHosts[1].Host != "other" || *jumpHosts[1].User != "admin" || *jumpHosts[1].Port != 2200 || *jumpHosts[1].PrivateKey != "target key" {
+					t.Errorf("unexpected second jump host %+v", jumpHosts[1])
+				}
+			},
+		},
+		{
+			name:   "explicit attributes win",
+			config: SSHConnectionConfig{Host: str("prod-db"), User: str("alice"), Port: new(int64), Agent: &agent},
+			check: func(t *testing.T, config SSHConnectionConfig, jumpHosts []SSHConnectionConfig) {
+				if *config.User != "alice" || *config.Port != 0 {
+					t.Errorf("explicit attributes were overridden: %s:%d", *config.User, *config.Port)
+				}
+				if config.PrivateKey != nil {
+					t.Error("IdentityFile was read although the agent is used")
+				}
+				if jumpHosts[1].Agent == nil || !*jumpHosts[1].Agent {
+					t.Error("jump host does not use the agent of the target host")
+				}
+			},
+		},
+		{
+			name: "secrets are not sent to jump hosts",
+			config: SSHConnectionConfig{
+				Host:                       str("prod-db"),
+				PrivateKey:                 str("target key"),
+				Password:                   str("secret"),
+				KeyboardInteractiveAnswers: map[string]string{"code": "1234"},
+				TOTPSecret:                 str("JBSWY3DPEHPK3PXP"),
+			},
+			check: func(t *testing.T, config SSHConnectionConfig, jumpHosts []SSHConnectionConfig) {
+				for _, hop := range jumpHosts {
+					if hop.Password != nil || hop.KeyboardInteractiveAnswers != nil || hop.TOTPSecret != nil {
+						t.Errorf("jump host %s received the secrets of the target host", *hop.Host)
+					}
+				}
+			},
+		},
+		{
+			name:    "jump host without identity",
+			config:  SSHConnectionConfig{Host: str("prod-db"), Password: str("secret")},
+			wantErr: "no identity is available for jump host other",
+		},
+		{
+			name:   "jump host without user",
+			config: SSHConnectionConfig{Host: str("through-unnamed"), PrivateKey: str("target key")},
+			check: func(t *testing.T, config SSHConnectionConfig, jumpHosts []SSHConnectionConfig) {
+				if *config.User != "deploy" || len(jumpHosts) != 1 {
+					t.Fatalf("unexpected target %+v and jump hosts %v", config, jumpHosts)
+				}
+				if jumpHosts[0].User != nil {
+					t.Errorf("jump host took the user %q of the target host", *jumpHosts[0].User)
+				}
+			},
+		},
+		{
+			name:   "proxy command replaces proxy jump",
+			config: SSHConnectionConfig{Host: str("proxied")},
+			check: func(t *testing.T, config SSHConnectionConfig, jumpHosts []SSHConnectionConfig) {
+				if config.ProxyCommand == nil || *config.ProxyCommand != "nc %h %p" || len(jumpHosts) != 0 {
+					t.Errorf("unexpected proxy command %v and jump hosts %v", config.ProxyCommand, jumpHosts)
+				}
+			},
+		},
+		{
+			name:   "unknown host",
+			config: SSHConnectionConfig{Host: str("example.com")},
+			check: func(t *testing.T, config SSHConnectionConfig, jumpHosts []SSHConnectionConfig) {
+				if *config.Host != "example.com" || config.User != nil || len(jumpHosts) != 0 {
+					t.Errorf("unexpected config %+v", config)
+				}
+			},
+		},
+		{
+			name:    "invalid port",
+			config:  SSHConnectionConfig{Host: str("bad-port")},
+			wantErr: `invalid Port "ssh" for host bad-port`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, jumpHosts, err := applySSHConfig(cfg, tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, config, jumpHosts)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/crypto/ssh"
+)
+
+// tunnel forwards the connections accepted on a listener to a target address. Local
+// tunnels listen locally and reach the target from the SSH server, remote forwards listen
+// on the SSH server and reach the target locally.
+type tunnel struct {
+	listener net.Listener
+	target   string
+	// dial connects to the target, returning the function to call once the connection is done
+	dial func(ctx context.Context) (net.Conn, func(), error)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// tunnelIDs numbers the tunnels opened by the provider process
+var tunnelIDs atomic.Int64
+
+// OpenTunnel listens on the local address and forwards every accepted connection to the
+// remote address through the client returned by dial. It returns the ID used to close the
+// tunnel and the address actually listened on.
+func (m *SSHManager) OpenTunnel(ctx context.Context, local string, remote string, dial func(ctx context.Context) (*ssh.Client, error)) (string, *net.TCPAddr, error) {
+	listener, err := net.Listen("tcp", local)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to listen on %s: %w", local, err)
+	}
+
+	tunnel := newTunnel(ctx, listener, remote, func(ctx context.Context) (net.Conn, func(), error) {
+		client, err := dial(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		conn, err := client.Dial("tcp", remote)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Keep the connection from being closed as idle while data is forwarded
+		return conn, m.trackSession(client), nil
+	})
+
+	id := strconv.FormatInt(tunnelIDs.Add(1), 10)
+	m.tunnelsLock.Lock()
+	m.tunnels[id] = tunnel
+	m.tunnelsLock.Unlock()
+
+	tflog.Debug(ctx, fmt.Sprintf("Forwarding %s to %s", listener.Addr(), remote))
+	return id, listener.Addr().(*net.TCPAddr), nil
+}
+
+// CloseTunnel stops the tunnel from accepting connections and closes the forwarded ones
+func (m *SSHManager) CloseTunnel(id string) {
+	m.tunnelsLock.Lock()
+	tunnel, ok := m.tunnels[id]
+	delete(m.tunnels, id)
+	m.tunnelsLock.Unlock()
+
+	if ok {
+		tunnel.close()
+	}
+}
+
+// closeTunnels closes every open tunnel
+func (m *SSHManager) closeTunnels() {
+	m.tunnelsLock.Lock()
+	tunnels := m.tunnels
+	m.tunnels = make(map[string]*tunnel)
+	m.tunnelsLock.Unlock()
+
+	for _, tunnel := range tunnels {
+		tunnel.close()
+	}
+}
+
+// newTunnel starts forwarding the connections accepted on the listener to the target
+func newTunnel(ctx context.Context, listener net.Listener, target string, dial func(ctx context.Context) (net.Conn, func(), error)) *tunnel {
+	t := &tunnel{
+		listener: listener,
+		target:   target,
+		dial:     dial,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	t.ctx, t.cancel = context.WithCancel(context.WithoutCancel(ctx))
+
+	t.wg.Add(1)
+	go t.accept()
+	return t
+}
+
+// accept forwards the connections accepted by the tunnel until it is closed
+func (t *tunnel) accept() {
+	defer t.wg.Done()
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			if err := t.forward(conn); err != nil {
+				tflog.Warn(t.ctx, fmt.Sprintf("Failed to forward connection from %s to %s: %s", conn.RemoteAddr(), t.target, err))
+			}
+		}()
+	}
+}
+
+// forward carries a single accepted connection to the target
+func (t *tunnel) forward(conn net.Conn) error {
+	defer conn.Close()
+	if !t.track(conn) {
+		return nil
+	}
+	defer t.untrack(conn)
+
+	target, done, err := t.dial(t.ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	defer target.Close()
+	if !t.track(target) {
+		return nil
+	}
+	defer t.untrack(target)
+
+	pipe(conn, target)
+	return nil
+}
+
+// track registers a connection to close along with the tunnel, unless it is already closed
+func (t *tunnel) track(conn net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ctx.Err() != nil {
+		return false
+	}
+	t.conns[conn] = struct{}{}
+	return true
+}
+
+func (t *tunnel) untrack(conn net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, conn)
+}
+
+// RemoteForwardConfig is a port of the SSH server forwarded back to a local address
+type RemoteForwardConfig struct {
+	Remote string
+	Local  string
+}
+
+// OpenRemoteForwards requests the SSH server to listen on the remote address of every
+// forward, and forwards the connections it accepts to their local address. The returned
+// function closes all forwards, which must not outlive the command they were opened for.
+func (m *SSHManager) OpenRemoteForwards(ctx context.Context, client *ssh.Client, forwards []RemoteForwardConfig) (func(), error) {
+	var tunnels []*tunnel
+	closeAll := func() {
+		for _, t := range tunnels {
+			t.close()
+		}
+	}
+
+	for _, forward := range forwards {
+		listener, err := client.Listen("tcp", forward.Remote)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("unable to listen on %s on the SSH server, which may not allow TCP forwarding: %w", forward.Remote, err)
+		}
+
+		local := forward.Local
+		tunnels = append(tunnels, newTunnel(ctx, listener, local, func(ctx context.Context) (net.Conn, func(), error) {
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "tcp", local)
+			return conn, func() {}, err
+		}))
+		tflog.Debug(ctx, fmt.Sprintf("Forwarding %s on the SSH server to %s", forward.Remote, local))
+	}
+
+	return closeAll, nil
+}
+
+// close stops accepting connections, closes the forwarded ones and waits for them to finish
+func (t *tunnel) close() {
+	t.mu.Lock()
+	t.cancel()
+	t.listener.Close()
+	for conn := range t.conns {
+		conn.Close()
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+}
+
+// pipe copies data in both directions until both sides are done, propagating half-closes
+func pipe(a net.Conn, b net.Conn) {
+	var wg sync.WaitGroup
+	copyHalf := func(dst net.Conn, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		if closer, ok := dst.(interface{ CloseWrite() error }); ok {
+			closer.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+	wg.Add(2)
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+	wg.Wait()
+}
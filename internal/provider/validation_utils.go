@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// validateConnection reports misconfigured connection settings of a resource or data
+// source. Users and credentials can also come from the OpenSSH client configuration, so
+// they are only required once the provider is configured without it. The manager is nil
+// when the provider is not configured yet.
+func validateConnection(manager *SSHManager, connectionName types.String, target *SSHConnectionModel, useProviderAsBastion types.Bool, bastion *SSHConnectionModel, jumpHosts []SSHConnectionModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	checkCredentials := manager != nil && manager.sshConfig == nil
+
+	if useProviderAsBastion.ValueBool() && bastion != nil {
+		diags.AddAttributeError(
+			path.Root("bastion"),
+			"Conflicting bastion configuration",
+			"bastion cannot be set together with use_provider_as_bastion = true, which already uses the provider's connection as the bastion. Remove one of them.",
+		)
+	}
+
+	if manager != nil && !connectionName.IsNull() && !connectionName.IsUnknown() {
+		if _, ok := manager.connections[connectionName.ValueString()]; !ok {
+			diags.AddAttributeError(
+				path.Root("connection_name"),
+				"Unknown connection",
+				fmt.Sprintf("The provider does not define a connection named %q. %s", connectionName.ValueString(), describeConnections(manager.connections)),
+			)
+		}
+	}
+
+	// Without a host or a named connection, the provider's connection is used as is
+	if connectionName.IsNull() && !target.Host.IsNull() && checkCredentials {
+		validateUser(path.Empty(), target, "the target host", &diags)
+	}
+
+	validateHops(path.Root("bastion"), path.Root("jump_hosts"), bastion, jumpHosts, checkCredentials, &diags)
+	return diags
+}
+
+// validateHops reports bastions and jump hosts lacking a host, a user or a way to authenticate
+func validateHops(bastionPath path.Path, jumpHostsPath path.Path, bastion *SSHConnectionModel, jumpHosts []SSHConnectionModel, checkCredentials bool, diags *diag.Diagnostics) {
+	if bastion != nil {
+		validateHop(bastionPath, bastion, "The bastion", checkCredentials, diags)
+	}
+	for i := range jumpHosts {
+		validateHop(jumpHostsPath.AtListIndex(i), &jumpHosts[i], fmt.Sprintf("Jump host %d", i+1), checkCredentials, diags)
+	}
+}
+
+// validateHop reports a bastion or jump host lacking a host, a user or a way to authenticate
+func validateHop(hopPath path.Path, hop *SSHConnectionModel, name string, checkCredentials bool, diags *diag.Diagnostics) {
+	if hop.Host.IsNull() {
+		diags.AddAttributeError(
+			hopPath.AtName("host"),
+			"Missing host",
+			fmt.Sprintf("%s has no host. Set host to the address of the server to tunnel through.", name),
+		)
+		return
+	}
+	if !checkCredentials {
+		return
+	}
+
+	validateUser(hopPath, hop, strings.ToLower(name[:1])+name[1:], diags)
+	if !hasAuthentication(hop) {
+		diags.AddAttributeError(
+			hopPath,
+			"Missing authentication",
+			fmt.Sprintf("%s has no authentication method. Set password, password_file, private_key, private_key_path, agent = true, keyboard_interactive_answers or totp_secret.", name),
+		)
+	}
+}
+
+// validateUser reports a connection to a host without a user
+func validateUser(connectionPath path.Path, connection *SSHConnectionModel, name string, diags *diag.Diagnostics) {
+	if connection.User.IsNull() {
+		diags.AddAttributeError(
+			connectionPath.AtName("user"),
+			"Missing user",
+			fmt.Sprintf("No user is set for %s. Set user, or enable use_ssh_config on the provider to take it from the OpenSSH client configuration.", name),
+		)
+	}
+}
+
+// hasAuthentication reports whether a connection has at least one way to authenticate.
+// Unknown values count as set.
+func hasAuthentication(connection *SSHConnectionModel) bool {
+	return !connection.Password.IsNull() || !connection.PasswordFile.IsNull() ||
+		!connection.PrivateKey.IsNull() || !connection.PrivateKeyPath.IsNull() ||
+		connection.Agent.ValueBool() || connection.Agent.IsUnknown() ||
+		!connection.KeyboardInteractiveAnswers.IsNull() ||
+		!connection.TOTPSecret.IsNull()
+}
+
+// describeConnections lists the named connections for error messages
+func describeConnections(connections map[string]SSHConnectionProfile) string {
+	if len(connections) == 0 {
+		return "The provider has no connections."
+	}
+	names := make([]string, 0, len(connections))
+	for name := range connections {
+		names = append(names, fmt.Sprintf("%q", name))
+	}
+	sort.Strings(names)
+	return "The provider's connections are " + strings.Join(names, ", ") + "."
+}
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/kevinburke/ssh_config"
+)
+
+func TestValidateConnection(t *testing.T) {
+	configured := &SSHManager{connections: map[string]SSHConnectionProfile{"prod": {}}}
+	withSSHConfig := &SSHManager{sshConfig: &ssh_config.Config{}}
+	host, user, password := types.StringValue("example.com"), types.StringValue("deploy"), types.StringValue("secret")
+
+	tests := []struct {
+		name                 string
+		manager              *SSHManager
+		connectionName       types.String
+		target               SSHConnectionModel
+		useProviderAsBastion bool
+		bastion              *SSHConnectionModel
+		jumpHosts            []SSHConnectionModel
+		// want maps the attribute paths of the expected errors to their summaries
+		want map[string]string
+	}{
+		{name: "complete", manager: configured, target: SSHConnectionModel{Host: host, User: user}, bastion: &SSHConnectionModel{Host: host, User: user, Password: password}},
+		{name: "provider connection", manager: configured},
+		{name: "known connection", manager: configured, connectionName: types.StringValue("prod")},
+		{name: "unknown connection", manager: configured, connectionName: types.StringValue("staging"), want: map[string]string{"connection_name": "Unknown connection"}},
+		{name: "unknown connection before configure", connectionName: types.StringValue("staging")},
+		{name: "missing user", manager: configured, target: SSHConnectionModel{Host: host}, want: map[string]string{"user": "Missing user"}},
+		{name: "user from ssh config", manager: withSSHConfig, target: SSHConnectionModel{Host: host}, jumpHosts: []SSHConnectionModel{{Host: host}}},
+		{name: "conflicting bastion", manager: configured, useProviderAsBastion: true, bastion: &SSHConnectionModel{Host: host, User: user, Password: password}, want: map[string]string{"bastion": "Conflicting bastion configuration"}},
+		{name: "bastion without host", bastion: &SSHConnectionModel{User: user}, want: map[string]string{"bastion.host": "Missing host"}},
+		{
+			name: "jump host without credentials", manager: configured,
+			jumpHosts: []SSHConnectionModel{{Host: host, User: user, Password: password}, {Host: host}},
+			want:      map[string]string{"jump_hosts[1].user": "Missing user", "jump_hosts[1]": "Missing authentication"},
+		},
+		{name: "unknown agent counts as authentication", manager: configured, jumpHosts: []SSHConnectionModel{{Host: host, User: user, Agent: types.BoolUnknown()}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := validateConnection(tt.manager, tt.connectionName, &tt.target, types.BoolValue(tt.useProviderAsBastion), tt.bastion, tt.jumpHosts)
+
+			got := map[string]string{}
+			for _, d := range diags.Errors() {
+				got[d.(diag.DiagnosticWithPath).Path().String()] = d.Summary()
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected errors %v, got %v", tt.want, got)
+			}
+			for attrPath, summary := range tt.want {
+				if got[attrPath] != summary {
+					t.Errorf("expected %q at %s, got %v", summary, attrPath, got)
+				}
+			}
+		})
+	}
+}
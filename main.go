@@ -37,6 +37,9 @@ func main() {
 
 	err := providerserver.Serve(context.Background(), provider.New(version), opts)
 
+	// Close the SSH connections once Terraform has stopped the provider
+	provider.Shutdown()
+
 	if err != nil {
 		log.Fatal(err.Error())
 	}